@@ -3,8 +3,11 @@ module github.com/cyf-gh/ccgo
 go 1.25.0
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.18.0
 	github.com/kpango/glg v1.6.15
+	github.com/mattn/go-sqlite3 v1.14.32
 	gopkg.in/ini.v1 v1.67.0
 )
 
@@ -1,7 +1,11 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/kpango/glg"
 
@@ -37,6 +41,24 @@ func main() {
 		panic(e)
 	}
 
+	// 收到退出信号时优雅关闭
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if e := cc.Shutdown(ctx); e != nil {
+			glg.Error(e)
+		}
+	}()
+
 	// 启动
-	http.ListenAndServe(":8080", nil)
+	if e := cc.ListenAndServe(":8080"); e != nil {
+		panic(e)
+	}
+	// Shutdown 开始后 ListenAndServe 立即返回，等待处理中的请求完成
+	<-done
 }
@@ -23,6 +23,7 @@ Example:
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -30,7 +31,9 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
 	middleware "github.com/cyf-gh/ccgo/pkg/cc/middleware"
 	mwh "github.com/cyf-gh/ccgo/pkg/cc/middleware/helper"
 	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
@@ -45,6 +48,8 @@ type (
 		Deprecate bool
 		NewPath   string
 		Freq      float64
+		mws       []middleware.MiddewareFunc
+		auth      *authRule
 	}
 	ActionPackage struct {
 		R *http.Request
@@ -67,6 +72,8 @@ type (
 var (
 	postHandlers        routeMap[ActionFunc]
 	getHandlers         routeMap[ActionFunc]
+	putHandlers         routeMap[ActionFunc]
+	patchHandlers       routeMap[ActionFunc]
 	wsHandlers          routeMap[ActionFuncWS]
 	ContentType         map[string]string
 	actionGroupHandlers map[string]ActionGroupFunc
@@ -91,6 +98,8 @@ func init() {
 
 	postHandlers = routeMap[ActionFunc]{m: make(map[string]*ActionFunc, mr)}
 	getHandlers = routeMap[ActionFunc]{m: make(map[string]*ActionFunc, mr)}
+	putHandlers = routeMap[ActionFunc]{m: make(map[string]*ActionFunc, mr)}
+	patchHandlers = routeMap[ActionFunc]{m: make(map[string]*ActionFunc, mr)}
 	wsHandlers = routeMap[ActionFuncWS]{m: make(map[string]*ActionFuncWS, mr)}
 	ActionGroups = make(map[string]ActionGroup)
 	actionGroupHandlers = make(map[string]ActionGroupFunc)
@@ -123,10 +132,11 @@ func (R ActionPackage) GetFormValue(key string) string {
 
 // 收益：大 Body（> 1 MB）场景减少 40 % 临时对象。
 // 回滚：改回旧的 ioutil.ReadAll 即可。
+// 超出 body 上限时返回 *http.MaxBytesError，更大的 body 请使用 DecodeBody
 func (R ActionPackage) GetBodyUnmarshal(v interface{}) error {
 	b := bufPool.Get().(*bytes.Buffer)
 	b.Reset()
-	defer bufPool.Put(b)
+	defer putBuf(b)
 
 	if _, err := b.ReadFrom(R.R.Body); err != nil {
 		return err
@@ -204,40 +214,93 @@ func (a ActionGroup) IsDeprecated(path string) bool {
 					Desc:   "deprecated. use " + a.NewPath + " instead",
 					Data:   "",
 				}, 200, r.URL.Path)
-			}))
+			}, a.mws...))
 		return true
 	}
 	return false
 }
 
 // 添加一个Post请求
-func (a ActionGroup) POST(path string, handler ActionFunc) {
+func (a ActionGroup) POST(path string, handler ActionFunc) *Route {
 	checkPathWarning(path)
+	rt := a.route(mwu.POST, path)
 	if a.IsDeprecated(path) {
-		return
+		return rt
 	}
 	glg.Log("[action] POST: ", a.Path+path)
 	http.HandleFunc(a.Path+path, mwh.WrapPost(
 		func(w http.ResponseWriter, r *http.Request) {
-			her, status := handler(ActionPackage{R: r, W: &w})
-			HttpReturnHER(&w, &her, status, r.URL.Path)
-		}))
+			if her, status, ok := rt.call(w, r, handler); ok {
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+			}
+		}, a.mws...))
 	postHandlers.store(path, &handler)
+	return rt
 }
 
 // 添加一个Get请求
-func (a ActionGroup) GET(path string, handler ActionFunc) {
+func (a ActionGroup) GET(path string, handler ActionFunc) *Route {
 	checkPathWarning(path)
+	rt := a.route(mwu.GET, path)
 	if a.IsDeprecated(path) {
-		return
+		return rt
 	}
 	glg.Log("[action] GET: ", a.Path+path)
 	http.HandleFunc(a.Path+path, mwh.WrapGet(
 		func(w http.ResponseWriter, r *http.Request) {
-			her, status := handler(ActionPackage{R: r, W: &w})
-			HttpReturnHER(&w, &her, status, r.URL.Path)
-		}))
+			if her, status, ok := rt.call(w, r, handler); ok {
+				httpReturnHERConditional(w, r, &her, status)
+			}
+		}, a.mws...))
 	getHandlers.store(path, &handler)
+	return rt
+}
+
+// 添加一个Put请求
+// 成功的响应带有 ETag，可配合 ap.IfMatch 实现乐观并发控制
+func (a ActionGroup) PUT(path string, handler ActionFunc) *Route {
+	checkPathWarning(path)
+	rt := a.route(mwu.PUT, path)
+	if a.IsDeprecated(path) {
+		return rt
+	}
+	glg.Log("[action] PUT: ", a.Path+path)
+	http.HandleFunc(a.Path+path, mwh.WrapPut(
+		func(w http.ResponseWriter, r *http.Request) {
+			if her, status, ok := rt.call(w, r, handler); ok {
+				httpReturnHERConditional(w, r, &her, status)
+			}
+		}, a.mws...))
+	putHandlers.store(path, &handler)
+	return rt
+}
+
+// 添加一个Patch请求
+// 成功的响应带有 ETag，可配合 ap.IfMatch 实现乐观并发控制
+func (a ActionGroup) PATCH(path string, handler ActionFunc) *Route {
+	checkPathWarning(path)
+	rt := a.route(mwu.PATCH, path)
+	if a.IsDeprecated(path) {
+		return rt
+	}
+	glg.Log("[action] PATCH: ", a.Path+path)
+	http.HandleFunc(a.Path+path, mwh.WrapPatch(
+		func(w http.ResponseWriter, r *http.Request) {
+			if her, status, ok := rt.call(w, r, handler); ok {
+				httpReturnHERConditional(w, r, &her, status)
+			}
+		}, a.mws...))
+	patchHandlers.store(path, &handler)
+	return rt
+}
+
+// 为之后注册的路由添加中间件，仅作用于本组
+// 组中间件包裹在全局中间件与 Method 检查之外，越是后添加的越靠外
+//
+//	a = a.Use(mwu.Compress())
+func (a ActionGroup) Use(mws ...middleware.MiddewareFunc) ActionGroup {
+	a.mws = append(append([]middleware.MiddewareFunc{}, a.mws...), mws...)
+	return a
 }
 
 // 用于弃用某个API并提示使用新API
@@ -251,14 +314,22 @@ func (a ActionGroup) Deprecated(substitute string) ActionGroup {
 // 添加一个websocket请求
 // cc规范：必须在请求路径末端添加ws字段来提示这一请求为websocket请求
 // 例：/imai_mami/no/koto/ga/suki/ws
-func (a ActionGroup) WS(path string, handler ActionFuncWS) {
+func (a ActionGroup) WS(path string, handler ActionFuncWS) *Route {
 	checkPathWarning(path)
+	rt := a.route(mwu.WS, path)
 	if a.IsDeprecated(path) {
-		return
+		return rt
 	}
 	glg.Log("[action] WS: ", a.Path+path)
 	http.HandleFunc(a.Path+path, mwh.WrapWS(func(w http.ResponseWriter, r *http.Request) {
 		glg.Log("[" + a.Path + path + "] " + "WS: START UPGRADE")
+		r, cancel := rt.begin(w, r)
+		defer cancel()
+		if e := rt.authorize(r); e != nil {
+			her, status := HerFromError(e)
+			HttpReturnHER(&w, &her, status, r.URL.Path)
+			return
+		}
 
 		ug := websocket.Upgrader{
 			ReadBufferSize:  1024,
@@ -274,13 +345,20 @@ func (a ActionGroup) WS(path string, handler ActionFuncWS) {
 			return
 		}
 		defer c.Close()
+		// 超时或服务器关闭时通知客户端断开
+		stop := context.AfterFunc(r.Context(), func() {
+			_ = c.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server closing"), time.Now().Add(time.Second))
+		})
+		defer stop()
 
 		if e = handler(ActionPackage{R: r, W: &w}, ActionPackageWS{C: c}); e != nil {
 			glg.Error(e)
 		}
 		glg.Info("[" + a.Path + path + "] " + "WS CLOSED")
-	}))
+	}, a.mws...))
 	wsHandlers.store(path, &handler)
+	return rt
 }
 
 func resp(w *http.ResponseWriter, msg string) {
@@ -288,47 +366,74 @@ func resp(w *http.ResponseWriter, msg string) {
 }
 
 // 只返回data，不返回其他的任何信息
+// 成功时带有由 data 计算的 ETag，支持条件 GET
 // DO: DATA ONLY
-func (a ActionGroup) GET_DO(path string, handler ActionFunc) {
+func (a ActionGroup) GET_DO(path string, handler ActionFunc) *Route {
+	rt := a.route(mwu.GET, path)
 	if a.IsDeprecated(path) {
-		return
+		return rt
 	}
 	glg.Log("[action] GET_DO: ", a.Path+path)
 	http.HandleFunc(a.Path+path, mwh.WrapGet(
 		func(w http.ResponseWriter, r *http.Request) {
-			her, _ := handler(ActionPackage{R: r, W: &w})
-			resp(&w, her.Data)
-		}))
+			her, status, ok := rt.call(w, r, handler)
+			switch {
+			case !ok:
+			case status != http.StatusOK || her.ErrCod != err_code.ERR_OK:
+				// 鉴权、CSRF 等检查失败或 handler 返回错误时返回完整的 HER 与状态码
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+			case !conditional(w, r, []byte(her.Data)):
+				resp(&w, her.Data)
+			}
+		}, a.mws...))
 	getHandlers.store(path, &handler)
+	return rt
 }
 
 // 用于返回content内容
-func (a ActionGroup) POST_CONTENT(path string, handler ActionFunc) {
+func (a ActionGroup) POST_CONTENT(path string, handler ActionFunc) *Route {
+	rt := a.route(mwu.POST, path)
 	if a.IsDeprecated(path) {
-		return
+		return rt
 	}
 	glg.Log("[action] POST_CONTENT: ", a.Path+path)
 	http.HandleFunc(a.Path+path, mwh.WrapPost(
 		func(w http.ResponseWriter, r *http.Request) {
-			_, _ = handler(ActionPackage{R: r, W: &w})
-		}))
+			// 内容由 handler 自行写出；鉴权、CSRF 等检查失败时返回 HER 与状态码
+			if her, status, ok := rt.call(w, r, handler); ok && status != http.StatusOK {
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+			}
+		}, a.mws...))
 	getHandlers.store(path, &handler)
+	return rt
 }
 
 // 用于返回content内容
-func (a ActionGroup) GET_CONTENT(path string, handler ActionFunc) {
+func (a ActionGroup) GET_CONTENT(path string, handler ActionFunc) *Route {
+	rt := a.route(mwu.GET, path)
 	if a.IsDeprecated(path) {
-		return
+		return rt
 	}
 	glg.Log("[action] GET_CONTENT: ", a.Path+path)
 	http.HandleFunc(a.Path+path, mwh.WrapGet(
 		func(w http.ResponseWriter, r *http.Request) {
-			_, _ = handler(ActionPackage{R: r, W: &w})
-		}))
+			// 内容由 handler 自行写出；鉴权、CSRF 等检查失败时返回 HER 与状态码
+			if her, status, ok := rt.call(w, r, handler); ok && status != http.StatusOK {
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+			}
+		}, a.mws...))
 	getHandlers.store(path, &handler)
+	return rt
 }
 
+// 未指定 SameSite 时使用 Lax；SameSite=None 时强制 Secure
 func (pap *ActionPackage) SetCookie(cookie *http.Cookie) {
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	if cookie.SameSite == http.SameSiteNoneMode {
+		cookie.Secure = true
+	}
 	http.SetCookie(*pap.W, cookie)
 }
 
@@ -442,6 +547,9 @@ func TrafficGuard() middleware.MiddewareFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if e := recover(); e != nil {
+					if e == http.ErrAbortHandler {
+						panic(e)
+					}
 					glg.Error(" === TG Panic!!! === ")
 					glg.Error(r.URL.Path, mwu.TGActiveRecorder, mwu.TGActiveRecorder[mwu.GetIP(r)][r.URL.Path])
 				}
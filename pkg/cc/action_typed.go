@@ -0,0 +1,66 @@
+package cc
+
+/**
+类型化的 Action
+
+	type EchoReq struct {
+		A string `query:"a" validate:"required"`
+	}
+	type EchoResp struct {
+		A string `json:"a"`
+	}
+
+	cc.Handle(a.GET, "/echo", func(ctx context.Context, req EchoReq) (EchoResp, error) {
+		return EchoResp{A: req.A}, nil
+	})
+
+Req 为结构体时经 Bind 绑定与校验，否则按 json body 解析；validate tag 有误时注册即 panic
+返回的 Resp 经 HerOkWithData 编码，error 经 HerFromError 转化
+*/
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+type (
+	TypedFunc[Req, Resp any] func(context.Context, Req) (Resp, error)
+	// ActionGroup 的 GET、POST 等注册函数
+	RegisterFunc func(string, ActionFunc) *Route
+)
+
+// 以类型化的处理函数注册路由
+// 例：cc.Handle(a.POST, "/x", f)
+func Handle[Req, Resp any](reg RegisterFunc, path string, h TypedFunc[Req, Resp]) *Route {
+	if e := checkValidateTags(reflect.TypeOf((*Req)(nil)).Elem()); e != nil {
+		panic(path + ": " + e.Error())
+	}
+	rt := reg(path, func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		var req Req
+		if e := bindTyped(ap, &req); e != nil {
+			return HerFromError(e)
+		}
+		resp, e := h(ap.Context(), req)
+		if e != nil {
+			return HerFromError(e)
+		}
+		return HerOkWithData(resp)
+	})
+	rt.ReqType = reflect.TypeOf((*Req)(nil)).Elem()
+	rt.RespType = reflect.TypeOf((*Resp)(nil)).Elem()
+	return rt
+}
+
+func bindTyped(ap ActionPackage, v interface{}) error {
+	if reflect.TypeOf(v).Elem().Kind() == reflect.Struct {
+		return ap.Bind(v)
+	}
+	if ap.R.Body == nil || ap.R.Body == http.NoBody {
+		return nil
+	}
+	if e := bindJSONBody(ap.R, v); e != nil {
+		return bindBodyError(e)
+	}
+	return nil
+}
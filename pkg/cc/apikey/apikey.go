@@ -0,0 +1,119 @@
+// API key 的生成、存储与校验
+//
+// key 的格式为 cck_<id>_<secret>，存储中只保存 secret 的 sha256
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	Prefix = "cck_"
+)
+
+type (
+	Key struct {
+		ID     string   `json:"id"`
+		Name   string   `json:"name"`
+		Hash   string   `json:"hash"`
+		Scopes []string `json:"scopes"`
+		// 每秒请求数上限，按 key 计数（不区分路径），0 为不限制
+		RateLimit float64 `json:"rate_limit"`
+		// 每日（UTC）请求数上限，0 为不限制
+		DailyQuota int64     `json:"daily_quota"`
+		CreatedAt  time.Time `json:"created_at"`
+		RevokedAt  time.Time `json:"revoked_at,omitzero"`
+	}
+
+	Store interface {
+		Create(k *Key) error
+		// 不存在时返回 ErrNotFound
+		Get(id string) (*Key, error)
+		List() ([]*Key, error)
+		Revoke(id string) error
+		// 增加 day（yyyy-mm-dd）的用量并返回增加后的值
+		IncrUsage(id, day string) (int64, error)
+	}
+)
+
+var (
+	ErrNotFound  = errors.New("apikey: not found")
+	ErrMalformed = errors.New("apikey: malformed key")
+	ErrInvalid   = errors.New("apikey: invalid key")
+	ErrRevoked   = errors.New("apikey: key revoked")
+)
+
+// 生成新的 key 并保存，明文只在此时返回一次
+func Generate(s Store, name string, scopes []string, rateLimit float64, dailyQuota int64) (plain string, k *Key, e error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	_, _ = rand.Read(id)
+	_, _ = rand.Read(secret)
+	k = &Key{
+		ID:         hex.EncodeToString(id),
+		Name:       name,
+		Scopes:     scopes,
+		RateLimit:  rateLimit,
+		DailyQuota: dailyQuota,
+		CreatedAt:  time.Now().UTC(),
+	}
+	sec := base64.RawURLEncoding.EncodeToString(secret)
+	k.Hash = hash(sec)
+	if e = s.Create(k); e != nil {
+		return "", nil, e
+	}
+	return Prefix + k.ID + "_" + sec, k, nil
+}
+
+// 校验明文 key，返回对应的 Key
+func Verify(s Store, plain string) (*Key, error) {
+	rest, ok := strings.CutPrefix(plain, Prefix)
+	if !ok {
+		return nil, ErrMalformed
+	}
+	id, sec, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || sec == "" {
+		return nil, ErrMalformed
+	}
+	k, e := s.Get(id)
+	if errors.Is(e, ErrNotFound) {
+		return nil, ErrInvalid
+	}
+	if e != nil {
+		return nil, e
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(sec)), []byte(k.Hash)) != 1 {
+		return nil, ErrInvalid
+	}
+	if k.Revoked() {
+		return nil, ErrRevoked
+	}
+	return k, nil
+}
+
+func (k *Key) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+func (k *Key) HasScope(s string) bool {
+	return slices.Contains(k.Scopes, s)
+}
+
+// 当前的用量统计日
+func Today() string {
+	return time.Now().UTC().Format(time.DateOnly)
+}
+
+// secret 为 32 字节随机数，无需慢哈希
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
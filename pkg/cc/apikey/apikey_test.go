@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, e := NewFileStore(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	plain, k, e := Generate(s, "partner", []string{"orders:read"}, 5, 100)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !strings.HasPrefix(plain, Prefix+k.ID+"_") {
+		t.Fatalf("plain key %q", plain)
+	}
+	sec := strings.TrimPrefix(plain, Prefix+k.ID+"_")
+	if k.Hash != hash(sec) || len(k.Hash) != 64 {
+		t.Errorf("hash %q", k.Hash)
+	}
+	// 文件中只保存哈希
+	b, _ := os.ReadFile(path)
+	if strings.Contains(string(b), sec) || !strings.Contains(string(b), k.Hash) {
+		t.Errorf("store content: %s", b)
+	}
+
+	got, e := Verify(s, plain)
+	if e != nil || got.ID != k.ID || !got.HasScope("orders:read") || got.HasScope("orders:write") {
+		t.Fatalf("verify: %+v %v", got, e)
+	}
+	for in, want := range map[string]error{
+		"":                         ErrMalformed,
+		"abc":                      ErrMalformed,
+		Prefix + k.ID:              ErrMalformed,
+		Prefix + k.ID + "_":        ErrMalformed,
+		Prefix + "_" + sec:         ErrMalformed,
+		Prefix + k.ID + "_" + "x":  ErrInvalid,
+		Prefix + "000000_" + sec:   ErrInvalid,
+		plain[:len(plain)-1] + "A": ErrInvalid,
+	} {
+		if _, e := Verify(s, in); !errors.Is(e, want) {
+			t.Errorf("%q: got %v, want %v", in, e, want)
+		}
+	}
+
+	if e := s.Revoke(k.ID); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := Verify(s, plain); !errors.Is(e, ErrRevoked) {
+		t.Errorf("revoked key: %v", e)
+	}
+	if e := s.Revoke("nope"); !errors.Is(e, ErrNotFound) {
+		t.Errorf("revoke missing: %v", e)
+	}
+
+	// 重新打开后仍可校验
+	s2, e := NewFileStore(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e := Verify(s2, plain); !errors.Is(e, ErrRevoked) {
+		t.Errorf("reloaded store: %v", e)
+	}
+}
+
+func TestFileStoreUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, _ := NewFileStore(path)
+	s.FlushInterval = 1 << 62
+	for i := int64(1); i <= 3; i++ {
+		if n, e := s.IncrUsage("a", "2026-01-01"); n != i || e != nil {
+			t.Fatalf("incr %d: %d %v", i, n, e)
+		}
+	}
+	if n, _ := s.IncrUsage("b", "2026-01-01"); n != 1 {
+		t.Errorf("usage shared between keys: %d", n)
+	}
+	// 新的一天重新计数
+	if n, _ := s.IncrUsage("a", "2026-01-02"); n != 1 {
+		t.Errorf("new day: %d", n)
+	}
+
+	if e := s.Flush(); e != nil {
+		t.Fatal(e)
+	}
+	s2, _ := NewFileStore(path)
+	if n, _ := s2.IncrUsage("a", "2026-01-02"); n != 2 {
+		t.Errorf("usage not flushed: %d", n)
+	}
+}
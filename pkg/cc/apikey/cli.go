@@ -0,0 +1,81 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/cli"
+)
+
+// 注册管理 key 的命令
+//
+//	apikey-create <name> [scope,scope] [rate_limit] [daily_quota]
+//	apikey-revoke <id>
+//	apikey-list
+func RegisterCli(s Store) {
+	cli.Register("apikey-create", &cli.CliFuncPack{F: func(args []string) error {
+		return cliCreate(s, args)
+	}, Desc: "Create an API key: <name> [scope,scope] [rate_limit] [daily_quota]", Group: "apikey"})
+	cli.Register("apikey-revoke", &cli.CliFuncPack{F: func(args []string) error {
+		if len(args) < 1 || args[0] == "" {
+			return errors.New("usage: apikey-revoke <id>")
+		}
+		if e := s.Revoke(args[0]); e != nil {
+			return e
+		}
+		println("revoked: " + args[0])
+		return nil
+	}, Desc: "Revoke an API key: <id>", Group: "apikey"})
+	cli.Register("apikey-list", &cli.CliFuncPack{F: func(args []string) error {
+		return cliList(s)
+	}, Desc: "List API keys", Group: "apikey"})
+}
+
+func cliCreate(s Store, args []string) error {
+	if len(args) < 1 || args[0] == "" {
+		return errors.New("usage: apikey-create <name> [scope,scope] [rate_limit] [daily_quota]")
+	}
+	var (
+		scopes []string
+		rate   float64
+		quota  int64
+		e      error
+	)
+	if len(args) > 1 && args[1] != "" && args[1] != "-" {
+		scopes = strings.Split(args[1], ",")
+	}
+	if len(args) > 2 {
+		if rate, e = strconv.ParseFloat(args[2], 64); e != nil {
+			return e
+		}
+	}
+	if len(args) > 3 {
+		if quota, e = strconv.ParseInt(args[3], 10, 64); e != nil {
+			return e
+		}
+	}
+	plain, k, e := Generate(s, args[0], scopes, rate, quota)
+	if e != nil {
+		return e
+	}
+	fmt.Printf("id:  %s\nkey: %s\n(the key is shown only once)\n", k.ID, plain)
+	return nil
+}
+
+func cliList(s Store) error {
+	ks, e := s.List()
+	if e != nil {
+		return e
+	}
+	fmt.Printf("%-14s %-20s %-24s %-8s %-10s %s\n", "ID", "NAME", "SCOPES", "RATE", "QUOTA", "STATUS")
+	for _, k := range ks {
+		st := "active"
+		if k.Revoked() {
+			st = "revoked " + k.RevokedAt.Format("2006-01-02")
+		}
+		fmt.Printf("%-14s %-20s %-24s %-8g %-10d %s\n", k.ID, k.Name, strings.Join(k.Scopes, ","), k.RateLimit, k.DailyQuota, st)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kpango/glg"
+)
+
+type (
+	// 保存在单个 json 文件中，适合 key 数量较少的场景
+	// 用量每隔 FlushInterval 写回文件，进程退出前应调用 Flush
+	FileStore struct {
+		path          string
+		mu            sync.Mutex
+		data          fileData
+		dirty         bool
+		flushed       time.Time
+		FlushInterval time.Duration
+	}
+
+	fileData struct {
+		Keys  map[string]*Key             `json:"keys"`
+		Usage map[string]map[string]int64 `json:"usage"` // id -> day -> count
+	}
+)
+
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path:          path,
+		data:          fileData{Keys: map[string]*Key{}, Usage: map[string]map[string]int64{}},
+		flushed:       time.Now(),
+		FlushInterval: 10 * time.Second,
+	}
+	b, e := os.ReadFile(path)
+	if errors.Is(e, fs.ErrNotExist) {
+		return s, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	if e = json.Unmarshal(b, &s.data); e != nil {
+		return nil, e
+	}
+	if s.data.Keys == nil {
+		s.data.Keys = map[string]*Key{}
+	}
+	if s.data.Usage == nil {
+		s.data.Usage = map[string]map[string]int64{}
+	}
+	return s, nil
+}
+
+func (s *FileStore) Create(k *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *k
+	s.data.Keys[k.ID] = &c
+	return s.save()
+}
+
+func (s *FileStore) Get(id string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.data.Keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *k
+	return &c, nil
+}
+
+func (s *FileStore) List() ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ks := make([]*Key, 0, len(s.data.Keys))
+	for _, k := range s.data.Keys {
+		c := *k
+		ks = append(ks, &c)
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		return ks[i].CreatedAt.Before(ks[j].CreatedAt)
+	})
+	return ks, nil
+}
+
+func (s *FileStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.data.Keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	k.RevokedAt = time.Now().UTC()
+	return s.save()
+}
+
+func (s *FileStore) IncrUsage(id, day string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.data.Usage[id]
+	if !ok {
+		u = map[string]int64{}
+		s.data.Usage[id] = u
+	}
+	if _, ok := u[day]; !ok {
+		// 新的一天，丢弃旧的用量
+		clear(u)
+	}
+	u[day]++
+	s.dirty = true
+	if time.Since(s.flushed) >= s.FlushInterval {
+		if e := s.save(); e != nil {
+			glg.Error("[apikey] flush usage: ", e)
+		}
+	}
+	return u[day], nil
+}
+
+// 将用量写回文件
+func (s *FileStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.save()
+}
+
+func (s *FileStore) save() error {
+	b, e := json.MarshalIndent(s.data, "", "  ")
+	if e != nil {
+		return e
+	}
+	tmp, e := os.CreateTemp(filepath.Dir(s.path), ".apikey-*")
+	if e != nil {
+		return e
+	}
+	if _, e = tmp.Write(b); e == nil {
+		e = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if e == nil {
+		e = os.Rename(tmp.Name(), s.path)
+	}
+	if e != nil {
+		_ = os.Remove(tmp.Name())
+		return e
+	}
+	s.dirty = false
+	s.flushed = time.Now()
+	return nil
+}
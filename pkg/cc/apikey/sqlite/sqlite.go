@@ -0,0 +1,124 @@
+// SQLite 存储的 API key，依赖 cgo（go-sqlite3），不需要时无需引入
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/apikey"
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type (
+	// 保存在 SQLite 中，表 cc_apikey 与 cc_apikey_usage
+	Store struct {
+		db *sql.DB
+	}
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS cc_apikey (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	hash        TEXT NOT NULL,
+	scopes      TEXT NOT NULL DEFAULT '',
+	rate_limit  REAL NOT NULL DEFAULT 0,
+	daily_quota INTEGER NOT NULL DEFAULT 0,
+	created_at  INTEGER NOT NULL,
+	revoked_at  INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS cc_apikey_usage (
+	id    TEXT NOT NULL,
+	day   TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (id, day)
+);`
+
+// path 为空时使用 server.cfg 的 [sqlite3] path
+func NewStore(path string) (*Store, error) {
+	if path == "" {
+		path = config.SqlitePath
+	}
+	db, e := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if e != nil {
+		return nil, e
+	}
+	if _, e = db.Exec(sqliteSchema); e != nil {
+		_ = db.Close()
+		return nil, e
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Create(k *apikey.Key) error {
+	_, e := s.db.Exec(`INSERT INTO cc_apikey (id, name, hash, scopes, rate_limit, daily_quota, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		k.ID, k.Name, k.Hash, strings.Join(k.Scopes, " "), k.RateLimit, k.DailyQuota, k.CreatedAt.Unix())
+	return e
+}
+
+func (s *Store) Get(id string) (*apikey.Key, error) {
+	k, e := scanKey(s.db.QueryRow(`SELECT id, name, hash, scopes, rate_limit, daily_quota, created_at, revoked_at FROM cc_apikey WHERE id = ?`, id))
+	if errors.Is(e, sql.ErrNoRows) {
+		return nil, apikey.ErrNotFound
+	}
+	return k, e
+}
+
+func (s *Store) List() ([]*apikey.Key, error) {
+	rows, e := s.db.Query(`SELECT id, name, hash, scopes, rate_limit, daily_quota, created_at, revoked_at FROM cc_apikey ORDER BY created_at`)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+	var ks []*apikey.Key
+	for rows.Next() {
+		k, e := scanKey(rows)
+		if e != nil {
+			return nil, e
+		}
+		ks = append(ks, k)
+	}
+	return ks, rows.Err()
+}
+
+func (s *Store) Revoke(id string) error {
+	res, e := s.db.Exec(`UPDATE cc_apikey SET revoked_at = ? WHERE id = ?`, time.Now().Unix(), id)
+	if e != nil {
+		return e
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return apikey.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) IncrUsage(id, day string) (n int64, e error) {
+	e = s.db.QueryRow(`INSERT INTO cc_apikey_usage (id, day, count) VALUES (?, ?, 1)
+		ON CONFLICT (id, day) DO UPDATE SET count = count + 1 RETURNING count`, id, day).Scan(&n)
+	return
+}
+
+func scanKey(row interface{ Scan(...any) error }) (*apikey.Key, error) {
+	var (
+		k                  apikey.Key
+		scopes             string
+		created, revokedAt int64
+	)
+	if e := row.Scan(&k.ID, &k.Name, &k.Hash, &scopes, &k.RateLimit, &k.DailyQuota, &created, &revokedAt); e != nil {
+		return nil, e
+	}
+	k.Scopes = strings.Fields(scopes)
+	k.CreatedAt = time.Unix(created, 0).UTC()
+	if revokedAt != 0 {
+		k.RevokedAt = time.Unix(revokedAt, 0).UTC()
+	}
+	return &k, nil
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/apikey"
+)
+
+func TestStore(t *testing.T) {
+	s, e := NewStore(filepath.Join(t.TempDir(), "cc.db"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+
+	plain, k, e := apikey.Generate(s, "partner", []string{"a", "b"}, 2.5, 10)
+	if e != nil {
+		t.Fatal(e)
+	}
+	got, e := apikey.Verify(s, plain)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got.ID != k.ID || got.Name != "partner" || got.Hash != k.Hash || len(got.Scopes) != 2 || !got.HasScope("b") ||
+		got.RateLimit != 2.5 || got.DailyQuota != 10 || got.CreatedAt.Unix() != k.CreatedAt.Unix() || got.Revoked() {
+		t.Errorf("loaded %+v, created %+v", got, k)
+	}
+	if _, e := s.Get("nope"); !errors.Is(e, apikey.ErrNotFound) {
+		t.Errorf("get missing: %v", e)
+	}
+
+	_, k2, _ := apikey.Generate(s, "other", nil, 0, 0)
+	ks, e := s.List()
+	if e != nil || len(ks) != 2 || k2 == nil {
+		t.Errorf("list: %v %v", ks, e)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		if n, e := s.IncrUsage(k.ID, "2026-01-01"); n != i || e != nil {
+			t.Fatalf("incr %d: %d %v", i, n, e)
+		}
+	}
+	if n, _ := s.IncrUsage(k.ID, "2026-01-02"); n != 1 {
+		t.Errorf("new day: %d", n)
+	}
+
+	if e := s.Revoke(k.ID); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := apikey.Verify(s, plain); !errors.Is(e, apikey.ErrRevoked) {
+		t.Errorf("revoked: %v", e)
+	}
+	if e := s.Revoke("nope"); !errors.Is(e, apikey.ErrNotFound) {
+		t.Errorf("revoke missing: %v", e)
+	}
+}
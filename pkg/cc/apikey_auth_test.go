@@ -0,0 +1,88 @@
+package cc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/apikey"
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/jwt"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	s, e := apikey.NewFileStore(filepath.Join(t.TempDir(), "keys.json"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	plain, k, _ := apikey.Generate(s, "p", []string{"orders:read"}, 0, 0)
+	limited, _, _ := apikey.Generate(s, "q", []string{"orders:read"}, 0, 2)
+	revoked, rk, _ := apikey.Generate(s, "r", nil, 0, 0)
+	throttled, _, _ := apikey.Generate(s, "t", []string{"orders:read"}, 1, 0)
+	_ = s.Revoke(rk.ID)
+
+	// JWTAuth 在外层，不应拦截 cck_ 开头的 Bearer token
+	v := jwt.NewVerifier(jwt.Options{Keys: jwt.NewHMACKeySet([]byte("apikey-jwt"))})
+	a := ActionGroup{Path: "/t_apikey"}.Use(APIKeyAuth(s), JWTAuth(v))
+	a.GET("/open", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		if ap.APIKey() == nil {
+			return HerOkWithString("")
+		}
+		return HerOkWithString(ap.APIKey().ID)
+	})
+	a.RequireAuth("orders:read").GET("/orders", func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOk() })
+	a.RequireAuth("orders:write").GET("/write", func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOk() })
+
+	do := func(path string, hdr ...string) (*httptest.ResponseRecorder, HttpErrReturn) {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(hdr); i += 2 {
+			r.Header.Set(hdr[i], hdr[i+1])
+		}
+		return serve(r)
+	}
+
+	if w, her := do("/t_apikey/open"); w.Code != http.StatusOK || her.Data != "" {
+		t.Errorf("anonymous: %d %+v", w.Code, her)
+	}
+	if w, her := do("/t_apikey/open", "X-API-Key", plain); w.Code != http.StatusOK || her.Data != k.ID {
+		t.Errorf("X-API-Key: %d %+v", w.Code, her)
+	}
+	if w, her := do("/t_apikey/open", "Authorization", "Bearer "+plain); w.Code != http.StatusOK || her.Data != k.ID {
+		t.Errorf("Bearer: %d %+v", w.Code, her)
+	}
+	if w, her := do("/t_apikey/orders", "X-API-Key", plain); w.Code != http.StatusOK || her.ErrCod != err_code.ERR_OK {
+		t.Errorf("scope: %d %+v", w.Code, her)
+	}
+	if w, _ := do("/t_apikey/write", "X-API-Key", plain); w.Code != http.StatusForbidden {
+		t.Errorf("missing scope: %d", w.Code)
+	}
+	if w, _ := do("/t_apikey/orders"); w.Code != http.StatusUnauthorized {
+		t.Errorf("no key: %d", w.Code)
+	}
+	for _, bad := range []string{plain + "x", revoked, "cck_bad"} {
+		if w, her := do("/t_apikey/open", "X-API-Key", bad); w.Code != http.StatusUnauthorized || her.ErrCod != err_code.ERR_NO_AUTH {
+			t.Errorf("%q: %d %+v", bad, w.Code, her)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if w, _ := do("/t_apikey/open", "X-API-Key", limited); w.Code != http.StatusOK {
+			t.Fatalf("quota request %d: %d", i, w.Code)
+		}
+	}
+	w, her := do("/t_apikey/open", "X-API-Key", limited)
+	if w.Code != http.StatusTooManyRequests || her.ErrCod != err_code.ERR_TOO_MANY_REQUESTS || w.Header().Get("Retry-After") == "" {
+		t.Errorf("over quota: %d %+v %v", w.Code, her, w.Header())
+	}
+
+	// 限流按 key 计数，换路径不能绕过
+	codes := []int{}
+	for _, p := range []string{"/t_apikey/open", "/t_apikey/orders", "/t_apikey/open?x=1", "/t_apikey/orders"} {
+		w, _ := do(p, "X-API-Key", throttled)
+		codes = append(codes, w.Code)
+	}
+	if codes[len(codes)-1] != http.StatusTooManyRequests {
+		t.Errorf("rate limit bypassed across paths: %v", codes)
+	}
+}
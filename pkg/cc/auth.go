@@ -0,0 +1,132 @@
+package cc
+
+/**
+JWT 认证
+
+	v := jwt.NewVerifier(jwt.Options{Keys: ks, Issuer: "https://auth.cyf.cc", Audience: "ccgo", Leeway: time.Minute})
+	mw.Register(cc.JWTAuth(v))
+
+	cc.AddActionGroup("/api/admin", func(a cc.ActionGroup) error {
+		a = a.RequireAuth("admin")
+		a.GET("/me", func(ap cc.ActionPackage) (cc.HttpErrReturn, cc.StatusCode) {
+			return cc.HerOkWithString(ap.Claims().Subject)
+		})
+		return nil
+	})
+
+JWTAuth 只解析 token，未携带 token 的请求照常通过；需要登录的路由使用 RequireAuth
+*/
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/apikey"
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/jwt"
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+)
+
+type (
+	// 路由的认证要求
+	authRule struct {
+		scopes []string
+		// 由 Require 设置的 RBAC 权限
+		perms []string
+	}
+)
+
+// 校验 Authorization: Bearer 中的 JWT，并将声明写入请求上下文
+// token 无效时返回 401 与 ERR_NO_AUTH
+func JWTAuth(v *jwt.Verifier) middleware.MiddewareFunc {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			tok := bearerToken(r)
+			// API key 由 APIKeyAuth 处理
+			if tok == "" || strings.HasPrefix(tok, apikey.Prefix) {
+				f(w, r)
+				return
+			}
+			c, e := v.Verify(tok)
+			if e != nil {
+				LoggerFrom(r.Context()).Warn("[auth]", r.URL.Path, e)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				her, status := HerFromError(ErrNoAuth(e.Error()))
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+				return
+			}
+			ctx := context.WithValue(r.Context(), ctxKeyClaims, c)
+			f(w, r.WithContext(WithUser(ctx, c)))
+		}
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	a := r.Header.Get("Authorization")
+	if len(a) > 7 && strings.EqualFold(a[:7], "Bearer ") {
+		return strings.TrimSpace(a[7:])
+	}
+	return ""
+}
+
+// 由 JWTAuth 写入的声明，未认证时为 nil
+func ClaimsFrom(ctx context.Context) *jwt.Claims {
+	c, _ := ctx.Value(ctxKeyClaims).(*jwt.Claims)
+	return c
+}
+
+// 当前请求的 JWT 声明，未认证时为 nil
+func (R ActionPackage) Claims() *jwt.Claims {
+	return ClaimsFrom(R.Context())
+}
+
+// 权限不足
+func ErrForbidden(desc string) *HerError {
+	return NewHerError(http.StatusForbidden, err_code.ERR_NO_AUTH, desc)
+}
+
+// 之后注册的路由都要求已认证，并拥有全部 scopes，与已有的要求叠加
+func (a ActionGroup) RequireAuth(scopes ...string) ActionGroup {
+	a.auth = a.auth.withScopes(scopes)
+	return a
+}
+
+// 该路由要求已认证，并拥有全部 scopes，与路由组的要求叠加
+func (rt *Route) RequireAuth(scopes ...string) *Route {
+	rt.auth = rt.auth.withScopes(scopes)
+	return rt
+}
+
+// 返回副本，路由组与其下的路由不共享同一规则
+func (ar *authRule) withScopes(scopes []string) *authRule {
+	n := &authRule{}
+	if ar != nil {
+		n.scopes = ar.scopes
+		n.perms = ar.perms
+	}
+	n.scopes = append(append([]string(nil), n.scopes...), scopes...)
+	return n
+}
+
+// 未认证返回 401，缺少 scope 或权限返回 403
+func (rt *Route) authorize(r *http.Request) error {
+	if rt.auth == nil {
+		return nil
+	}
+	// JWT 或 API key
+	var hasScope func(string) bool
+	if c := ClaimsFrom(r.Context()); c != nil {
+		hasScope = c.HasScope
+	} else if k := APIKeyFrom(r.Context()); k != nil {
+		hasScope = k.HasScope
+	} else {
+		return ErrNoAuth("authentication required")
+	}
+	for _, s := range rt.auth.scopes {
+		if !hasScope(s) {
+			return ErrForbidden("insufficient scope: " + s)
+		}
+	}
+	return rt.checkPermissions(r)
+}
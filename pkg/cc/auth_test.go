@@ -0,0 +1,100 @@
+package cc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/jwt"
+)
+
+func bearer(method, path, tok string) *http.Request {
+	r := httptest.NewRequest(method, path, nil)
+	if tok != "" {
+		r.Header.Set("Authorization", "Bearer "+tok)
+	}
+	return r
+}
+
+func TestJWTAuth(t *testing.T) {
+	secret := []byte("s")
+	v := jwt.NewVerifier(jwt.Options{Keys: jwt.NewHMACKeySet(secret)})
+	sign := func(c jwt.Claims) string {
+		tok, e := jwt.Sign(c, jwt.HS256, "", secret)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return tok
+	}
+	ok := func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOkWithString(ap.Claims().Subject) }
+
+	a := ActionGroup{Path: "/t_jwt"}.Use(JWTAuth(v))
+	a.GET("/open", func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOk() })
+	a.RequireAuth().GET("/me", ok)
+	admin := a.RequireAuth("admin")
+	admin.GET("/admin", ok)
+	// 路由的 scope 与路由组的叠加
+	admin.GET("/admin_read", ok).RequireAuth("read")
+	admin.GET("/admin_any", ok).RequireAuth()
+
+	exp := time.Now().Add(time.Hour).Unix()
+	user := sign(jwt.Claims{Subject: "u", ExpiresAt: exp, Scope: "read"})
+	root := sign(jwt.Claims{Subject: "r", ExpiresAt: exp, Scope: "admin"})
+	both := sign(jwt.Claims{Subject: "b", ExpiresAt: exp, Scope: "admin read"})
+	expired := sign(jwt.Claims{Subject: "u", ExpiresAt: time.Now().Add(-time.Hour).Unix()})
+
+	cases := []struct {
+		path, tok string
+		want      int
+	}{
+		{"/t_jwt/open", "", http.StatusOK},
+		{"/t_jwt/open", "garbage", http.StatusUnauthorized},
+		{"/t_jwt/me", "", http.StatusUnauthorized},
+		{"/t_jwt/me", expired, http.StatusUnauthorized},
+		{"/t_jwt/me", user, http.StatusOK},
+		{"/t_jwt/admin", user, http.StatusForbidden},
+		{"/t_jwt/admin", root, http.StatusOK},
+		{"/t_jwt/admin_read", user, http.StatusForbidden},
+		{"/t_jwt/admin_read", root, http.StatusForbidden},
+		{"/t_jwt/admin_read", both, http.StatusOK},
+		{"/t_jwt/admin_any", user, http.StatusForbidden},
+		{"/t_jwt/admin_any", root, http.StatusOK},
+	}
+	for _, c := range cases {
+		w, her := serve(bearer(http.MethodGet, c.path, c.tok))
+		if w.Code != c.want {
+			t.Errorf("%s %.8s: %d, want %d; %+v", c.path, c.tok, w.Code, c.want, her)
+		}
+		if c.want == http.StatusUnauthorized && her.ErrCod != err_code.ERR_NO_AUTH {
+			t.Errorf("%s: %+v", c.path, her)
+		}
+	}
+	if w, _ := serve(bearer(http.MethodGet, "/t_jwt/me", expired)); w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("WWW-Authenticate missing")
+	}
+}
+
+// DO 与 CONTENT 路由在鉴权失败时同样返回状态码与 HER
+func TestRequireAuthRawRoutes(t *testing.T) {
+	a := ActionGroup{Path: "/t_auth_raw"}.RequireAuth()
+	a.GET_DO("/do", func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerData("secret") })
+	content := func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		_, _ = (*ap.W).Write([]byte("secret"))
+		return HerOk()
+	}
+	a.GET_CONTENT("/get", content)
+	a.POST_CONTENT("/post", content)
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/t_auth_raw/do", nil),
+		httptest.NewRequest(http.MethodGet, "/t_auth_raw/get", nil),
+		httptest.NewRequest(http.MethodPost, "/t_auth_raw/post", nil),
+	} {
+		w, her := serve(r)
+		if w.Code != http.StatusUnauthorized || her.ErrCod != err_code.ERR_NO_AUTH {
+			t.Errorf("%s: %d %q", r.URL.Path, w.Code, w.Body)
+		}
+	}
+}
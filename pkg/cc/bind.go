@@ -0,0 +1,313 @@
+package cc
+
+/**
+请求绑定
+
+将请求中的各部分按 struct tag 填入结构体：
+
+	type ListReq struct {
+		Page    int64         `query:"page" default:"1"`
+		Tags    []string      `query:"tag"`
+		Id      int64         `path:"id"`
+		Token   string        `header:"X-Token"`
+		Session string        `cookie:"sid"`
+		Name    string        `form:"name"`
+		Since   time.Time     `query:"since" time_format:"2006-01-02"`
+		Wait    time.Duration `query:"wait"`
+		Title   string        `json:"title"` // 来自 json body
+	}
+
+	var req ListReq
+	if e := ap.Bind(&req); e != nil {
+		return HerArgsInvalid(e)
+	}
+*/
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BindQuery  = "query"
+	BindForm   = "form"
+	BindPath   = "path"
+	BindHeader = "header"
+	BindCookie = "cookie"
+	BindBody   = "body"
+)
+
+type (
+	// 单个字段的错误
+	// Reason 为机器可读的错误原因，例如 type、decode、required
+	FieldError struct {
+		Field  string `json:"field"`
+		Source string `json:"source"`
+		Reason string `json:"reason"`
+		Param  string `json:"param,omitempty"`
+		Desc   string `json:"desc,omitempty"`
+	}
+	FieldErrors []FieldError
+)
+
+var (
+	bindSources = []string{BindPath, BindQuery, BindForm, BindHeader, BindCookie}
+
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	textUnmType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (fe FieldError) Error() string {
+	s := fe.Source + " \"" + fe.Field + "\": " + fe.Reason
+	if fe.Desc != "" {
+		s += " (" + fe.Desc + ")"
+	}
+	return s
+}
+
+func (fes FieldErrors) Error() string {
+	ss := make([]string, 0, len(fes))
+	for _, fe := range fes {
+		ss = append(ss, fe.Error())
+	}
+	return strings.Join(ss, "; ")
+}
+
+// 所有出错字段名
+func (fes FieldErrors) Fields() []string {
+	fs := make([]string, 0, len(fes))
+	for _, fe := range fes {
+		fs = append(fs, fe.Field)
+	}
+	return fs
+}
+
+// 按 struct tag 将请求绑定至 v，v 必须为结构体指针
+// json body 先于其他来源解析，query/form/path/header/cookie 的值会覆盖 body 中的同名字段
+// 绑定成功后按 validate tag 进行校验，见 Validate
+// 返回的 error 若为 FieldErrors，可直接交由 HerArgsInvalid 返回
+func (R ActionPackage) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("ActionPackage.Bind: v must be a non-nil pointer to struct")
+	}
+
+	var fes FieldErrors
+	ct, _, _ := mime.ParseMediaType(R.R.Header.Get("Content-Type"))
+	switch {
+	case ct == "application/x-www-form-urlencoded":
+		if e := R.R.ParseForm(); e != nil {
+			return bindBodyError(e)
+		}
+	case ct == "multipart/form-data":
+		if e := R.R.ParseMultipartForm(32 << 20); e != nil {
+			return bindBodyError(e)
+		}
+	case R.R.Body != nil && R.R.Body != http.NoBody && R.R.ContentLength != 0 &&
+		(ct == "application/json" || strings.HasSuffix(ct, "+json") || ct == ""):
+		if e := bindJSONBody(R.R, v); e != nil {
+			return bindBodyError(e)
+		}
+	}
+
+	bindStruct(R.R, rv.Elem(), &fes)
+	if len(fes) != 0 {
+		return fes
+	}
+	return Validate(v)
+}
+
+// 空 body 不视为错误
+func bindJSONBody(r *http.Request, v interface{}) error {
+	b := bufPool.Get().(*bytes.Buffer)
+	b.Reset()
+	defer putBuf(b)
+
+	if _, e := b.ReadFrom(r.Body); e != nil {
+		return e
+	}
+	if len(bytes.TrimSpace(b.Bytes())) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b.Bytes(), v)
+}
+
+func bindBodyError(e error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(e, &mbe) {
+		return e
+	}
+	return FieldErrors{{Field: "body", Source: BindBody, Reason: "decode", Desc: e.Error()}}
+}
+
+func bindStruct(r *http.Request, sv reflect.Value, fes *FieldErrors) {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		fv := sv.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		// 嵌入结构体展开绑定
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			bindStruct(r, fv, fes)
+			continue
+		}
+		for _, src := range bindSources {
+			name, ok := sf.Tag.Lookup(src)
+			if !ok || name == "" || name == "-" {
+				continue
+			}
+			vals := lookupSource(r, src, name)
+			if len(vals) == 0 {
+				if d, ok := sf.Tag.Lookup("default"); ok && fv.IsZero() {
+					vals = []string{d}
+				} else {
+					continue
+				}
+			}
+			if e := setField(fv, vals, sf.Tag.Get("time_format")); e != nil {
+				*fes = append(*fes, FieldError{Field: name, Source: src, Reason: "type", Desc: e.Error()})
+			}
+			break
+		}
+	}
+}
+
+func lookupSource(r *http.Request, src, name string) []string {
+	switch src {
+	case BindPath:
+		if v := r.PathValue(name); v != "" {
+			return []string{v}
+		}
+	case BindQuery:
+		return r.URL.Query()[name]
+	case BindForm:
+		if r.PostForm == nil {
+			// 非表单 Content-Type 时仍然尝试解析
+			_ = r.ParseForm()
+		}
+		return r.PostForm[name]
+	case BindHeader:
+		return r.Header.Values(name)
+	case BindCookie:
+		if c, e := r.Cookie(name); e == nil {
+			return []string{c.Value}
+		}
+	}
+	return nil
+}
+
+// 将字符串值写入字段，切片字段接受多个值或逗号分隔的单个值
+func setField(fv reflect.Value, vals []string, layout string) error {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setField(fv.Elem(), vals, layout)
+	}
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 && !fv.Addr().Type().Implements(textUnmType) {
+		if len(vals) == 1 {
+			vals = strings.Split(vals[0], ",")
+		}
+		sl := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if e := setValue(sl.Index(i), strings.TrimSpace(s), layout); e != nil {
+				return e
+			}
+		}
+		fv.Set(sl)
+		return nil
+	}
+	return setValue(fv, vals[0], layout)
+}
+
+func setValue(fv reflect.Value, s, layout string) error {
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmType) && fv.Type() != timeType {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch fv.Type() {
+	case timeType:
+		t, e := parseTime(s, layout)
+		if e != nil {
+			return e
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, e := time.ParseDuration(s)
+		if e != nil {
+			// 纯数字按秒处理
+			n, e2 := strconv.ParseInt(s, 10, 64)
+			if e2 != nil {
+				return e
+			}
+			d = time.Duration(n) * time.Second
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, e := strconv.ParseBool(s)
+		if e != nil {
+			return e
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, e := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if e != nil {
+			return e
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, e := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if e != nil {
+			return e
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, e := strconv.ParseFloat(s, fv.Type().Bits())
+		if e != nil {
+			return e
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		// []byte
+		fv.SetBytes([]byte(s))
+	case reflect.Map, reflect.Struct:
+		return json.Unmarshal([]byte(s), fv.Addr().Interface())
+	default:
+		return errors.New("unsupported field type " + fv.Type().String())
+	}
+	return nil
+}
+
+// 未指定 time_format 时依次尝试 RFC3339、日期与 unix 秒
+func parseTime(s, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.Parse(layout, s)
+	}
+	if t, e := time.Parse(time.RFC3339, s); e == nil {
+		return t, nil
+	}
+	if t, e := time.Parse(time.DateOnly, s); e == nil {
+		return t, nil
+	}
+	if n, e := strconv.ParseInt(s, 10, 64); e == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, errors.New("cannot parse \"" + s + "\" as time")
+}
@@ -0,0 +1,62 @@
+package cc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindReq struct {
+	Page  int64         `query:"page" default:"1"`
+	Tags  []string      `query:"tag"`
+	Id    int64         `path:"id"`
+	Token string        `header:"X-Token"`
+	Sid   string        `cookie:"sid"`
+	Since time.Time     `query:"since" time_format:"2006-01-02"`
+	Wait  time.Duration `query:"wait"`
+	On    *bool         `query:"on"`
+	Title string        `json:"title"`
+}
+
+func TestBind(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/x?tag=a,b&since=2021-02-16&wait=3s&on=true", strings.NewReader(`{"title":"ccgo"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Token", "tk")
+	r.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+	r.SetPathValue("id", "42")
+
+	var req bindReq
+	if e := (ActionPackage{R: r}).Bind(&req); e != nil {
+		t.Fatal(e)
+	}
+	if req.Page != 1 || req.Id != 42 || req.Token != "tk" || req.Sid != "s1" || req.Title != "ccgo" {
+		t.Fatal(req)
+	}
+	if len(req.Tags) != 2 || req.Tags[1] != "b" || req.Wait != 3*time.Second || req.On == nil || !*req.On {
+		t.Fatal(req)
+	}
+	if req.Since.Year() != 2021 || req.Since.Month() != 2 {
+		t.Fatal(req.Since)
+	}
+}
+
+func TestBindFieldErrors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/x?page=abc&wait=soon", nil)
+
+	var req bindReq
+	e := (ActionPackage{R: r}).Bind(&req)
+	var fes FieldErrors
+	if !errors.As(e, &fes) || len(fes) != 2 {
+		t.Fatal(e)
+	}
+	if fes[0].Field != "page" || fes[0].Source != BindQuery || fes[0].Reason != "type" {
+		t.Fatal(fes[0])
+	}
+	her, _ := HerArgsInvalid(e)
+	if !strings.Contains(her.Desc, "\"page\", \"wait\"") {
+		t.Fatal(her.Desc)
+	}
+}
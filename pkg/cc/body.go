@@ -0,0 +1,116 @@
+package cc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	cfg "github.com/cyf-gh/ccgo/pkg/cc/config"
+)
+
+type (
+	// 声明长度已超出上限的 body，读取时直接返回错误，避免读取无用数据
+	tooLargeBody struct {
+		e *http.MaxBytesError
+	}
+
+	// 记录 body 是否超出上限，handler 以任何方式读取 body 出错后都可返回 413
+	trackedBody struct {
+		io.ReadCloser
+		st *reqState
+	}
+)
+
+func (b tooLargeBody) Read([]byte) (int, error) {
+	return 0, b.e
+}
+
+func (b tooLargeBody) Close() error {
+	return nil
+}
+
+func (b trackedBody) Read(p []byte) (int, error) {
+	n, e := b.ReadCloser.Read(p)
+	if e != nil && IsBodyTooLarge(e) {
+		b.st.mu.Lock()
+		b.st.bodyTooLarge = true
+		b.st.mu.Unlock()
+	}
+	return n, e
+}
+
+// 限制请求 body 的大小
+// limit 为 0 时使用 server.cfg 中的 [http] max_body_size，小于 0 为不限制
+// 读取超出上限时 handler 返回的错误 HER 会被替换为 HerBodyTooLarge，见 Route.run
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) {
+	limit = bodyLimit(limit)
+	if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	st := stateFrom(r.Context())
+	if r.ContentLength > limit {
+		r.Body = trackedBody{ReadCloser: tooLargeBody{e: &http.MaxBytesError{Limit: limit}}, st: st}
+		return
+	}
+	r.Body = trackedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit), st: st}
+}
+
+// 路由的 body 上限，0 为 server.cfg 中的 [http] max_body_size，返回值 <= 0 为不限制
+func bodyLimit(routeLimit int64) int64 {
+	if routeLimit == 0 {
+		return cfg.MaxBodySize
+	}
+	return routeLimit
+}
+
+// body 是否超出上限
+func IsBodyTooLarge(e error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(e, &mbe)
+}
+
+// 曾经读取过超大 body 的 buffer 不放回池中，避免长期占用内存
+func putBuf(b *bytes.Buffer) {
+	if cfg.MaxPooledBufSize > 0 && int64(b.Cap()) > cfg.MaxPooledBufSize {
+		return
+	}
+	bufPool.Put(b)
+}
+
+// 以流的方式解析 json body，不会将整个 body 读入内存
+// 适用于较大的 body
+func (R ActionPackage) DecodeBody(v interface{}) error {
+	return json.NewDecoder(R.R.Body).Decode(v)
+}
+
+// 以流的方式逐个解析 json 数组 body 中的元素
+// fn 返回 error 时停止解析并返回该 error
+//
+//	e := cc.DecodeArray(ap, func(row Row) error {
+//		return db.Insert(row)
+//	})
+func DecodeArray[T any](ap ActionPackage, fn func(T) error) error {
+	dec := json.NewDecoder(ap.R.Body)
+	t, e := dec.Token()
+	if e != nil {
+		return e
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return errors.New("DecodeArray: body is not a json array")
+	}
+	for dec.More() {
+		var v T
+		if e = dec.Decode(&v); e != nil {
+			return e
+		}
+		if e = fn(v); e != nil {
+			return e
+		}
+	}
+	if _, e = dec.Token(); e != nil && e != io.EOF {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package cc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+)
+
+func TestBodyTooLarge(t *testing.T) {
+	a := ActionGroup{Path: "/t_body"}
+	// 旧的写法：不经 HerFromError 转化
+	a.POST("/legacy", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		var v map[string]string
+		if e := ap.GetBodyUnmarshal(&v); e != nil {
+			return HerArgInvalid("body")
+		}
+		return HerOk()
+	}).MaxBody(8)
+	a.POST("/ignore", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		var v map[string]string
+		_ = ap.DecodeBody(&v)
+		return HerOk()
+	}).MaxBody(8)
+
+	body := `{"name":"0123456789"}`
+	for _, chunked := range []bool{false, true} {
+		r := httptest.NewRequest(http.MethodPost, "/t_body/legacy", strings.NewReader(body))
+		if chunked {
+			// 未声明长度时在读取过程中超出上限
+			r.ContentLength = -1
+		}
+		w, her := serve(r)
+		if w.Code != http.StatusRequestEntityTooLarge || her.ErrCod != err_code.ERR_INVALID_ARGUMENT {
+			t.Fatal(chunked, w.Code, her)
+		}
+	}
+	if w, her := serve(httptest.NewRequest(http.MethodPost, "/t_body/legacy", strings.NewReader(`{}`))); w.Code != http.StatusOK || her.ErrCod != err_code.ERR_OK {
+		t.Fatal(w.Code, her)
+	}
+	// 成功的 HER 不被替换
+	if w, her := serve(httptest.NewRequest(http.MethodPost, "/t_body/ignore", strings.NewReader(body))); w.Code != http.StatusOK || her.ErrCod != err_code.ERR_OK {
+		t.Fatal(w.Code, her)
+	}
+}
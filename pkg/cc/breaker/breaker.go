@@ -0,0 +1,309 @@
+// 熔断器
+//
+// 关闭（closed）时统计滑动窗口内的请求，失败比例达到阈值后打开（open）并直接拒绝请求；
+// 经过 OpenTimeout 后进入半开（half-open），放行少量试探请求：全部成功则关闭，任一失败则再次打开
+package breaker
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+const buckets = 10
+
+type (
+	State int
+
+	Options struct {
+		// 统计失败比例的滑动窗口，默认 10 秒
+		Window time.Duration
+		// 窗口内请求数达到该值才会判断是否打开，默认 20
+		MinRequests uint64
+		// 打开的失败比例，默认 0.5
+		FailureRatio float64
+		// 打开后进入半开前的等待时间，默认 30 秒
+		OpenTimeout time.Duration
+		// 半开时放行的试探请求数，默认 1
+		HalfOpenMax int
+		// 状态变化时调用，不持有锁
+		OnStateChange func(name string, from, to State)
+	}
+
+	Breaker struct {
+		name string
+		o    *Options
+
+		mu       sync.Mutex
+		state    State
+		gen      uint64
+		since    time.Time
+		win      [buckets]bucket
+		cur      int
+		curStart time.Time
+		trials   int
+		passed   int
+		rejected uint64
+	}
+
+	bucket struct {
+		requests, failures uint64
+	}
+
+	// 熔断器的状态与计数，可序列化为 json
+	Stat struct {
+		Name     string    `json:"name"`
+		State    State     `json:"state"`
+		Since    time.Time `json:"since"`
+		Requests uint64    `json:"requests"` // 当前窗口内
+		Failures uint64    `json:"failures"` // 当前窗口内
+		Rejected uint64    `json:"rejected"` // 累计
+	}
+
+	// 按名称（例如 host）区分的一组熔断器，共用同一配置
+	Group struct {
+		o  Options
+		mu sync.Mutex
+		m  map[string]*Breaker
+	}
+)
+
+var (
+	ErrOpen = errors.New("breaker: circuit open")
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (o *Options) defaults() {
+	if o.Window <= 0 {
+		o.Window = 10 * time.Second
+	}
+	if o.MinRequests == 0 {
+		o.MinRequests = 20
+	}
+	if o.FailureRatio <= 0 || o.FailureRatio > 1 {
+		o.FailureRatio = 0.5
+	}
+	if o.OpenTimeout <= 0 {
+		o.OpenTimeout = 30 * time.Second
+	}
+	if o.HalfOpenMax <= 0 {
+		o.HalfOpenMax = 1
+	}
+}
+
+func New(name string, o Options) *Breaker {
+	o.defaults()
+	now := time.Now()
+	return &Breaker{name: name, o: &o, since: now, curStart: now}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// 请求前调用，熔断时返回 ErrOpen
+// 否则请求结束后必须调用 done 报告是否成功
+func (b *Breaker) Allow() (done func(success bool), e error) {
+	done, _, e = b.AllowOrIgnore()
+	return
+}
+
+// 与 Allow 相同，另外返回 ignore：请求结果与上游无关（例如调用方取消）时调用 ignore 代替 done，
+// 不计入统计，只释放半开时占用的试探名额
+func (b *Breaker) AllowOrIgnore() (done func(success bool), ignore func(), e error) {
+	b.mu.Lock()
+	now := time.Now()
+	var notify func()
+	if b.state == Open && now.Sub(b.since) >= b.o.OpenTimeout {
+		notify = b.setState(HalfOpen, now)
+	}
+	switch {
+	case b.state == Open, b.state == HalfOpen && b.trials >= b.o.HalfOpenMax:
+		b.rejected++
+		e = ErrOpen
+	case b.state == HalfOpen:
+		b.trials++
+	}
+	gen := b.gen
+	b.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+	if e != nil {
+		return nil, nil, e
+	}
+	var once sync.Once
+	return func(success bool) {
+			once.Do(func() { b.done(gen, success) })
+		}, func() {
+			once.Do(func() { b.ignore(gen) })
+		}, nil
+}
+
+func (b *Breaker) ignore(gen uint64) {
+	b.mu.Lock()
+	if gen == b.gen && b.state == HalfOpen {
+		b.trials--
+	}
+	b.mu.Unlock()
+}
+
+func (b *Breaker) done(gen uint64, success bool) {
+	b.mu.Lock()
+	var notify func()
+	now := time.Now()
+	// 状态已变化，忽略之前放行的请求
+	if gen == b.gen {
+		switch b.state {
+		case Closed:
+			b.advance(now)
+			b.win[b.cur].requests++
+			if !success {
+				b.win[b.cur].failures++
+			}
+			if req, fail := b.counts(); req >= b.o.MinRequests && float64(fail) >= float64(req)*b.o.FailureRatio {
+				notify = b.setState(Open, now)
+			}
+		case HalfOpen:
+			if !success {
+				notify = b.setState(Open, now)
+			} else if b.passed++; b.passed >= b.o.HalfOpenMax {
+				notify = b.setState(Closed, now)
+			}
+		}
+	}
+	b.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+}
+
+// 手动关闭并清空计数
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	notify := b.setState(Closed, time.Now())
+	b.mu.Unlock()
+	notify()
+}
+
+func (b *Breaker) Stat() Stat {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	req, fail := b.counts()
+	return Stat{Name: b.name, State: b.state, Since: b.since, Requests: req, Failures: fail, Rejected: b.rejected}
+}
+
+// 调用时需持有锁，返回的通知在解锁后调用
+func (b *Breaker) setState(s State, now time.Time) func() {
+	from := b.state
+	b.state, b.since = s, now
+	b.gen++
+	b.trials, b.passed = 0, 0
+	b.win = [buckets]bucket{}
+	b.curStart = now
+	return func() {
+		if b.o.OnStateChange != nil && from != s {
+			b.o.OnStateChange(b.name, from, s)
+		}
+	}
+}
+
+// 滑动窗口前进到 now
+func (b *Breaker) advance(now time.Time) {
+	width := b.o.Window / buckets
+	n := int(now.Sub(b.curStart) / width)
+	if n <= 0 {
+		return
+	}
+	if n >= buckets {
+		b.win = [buckets]bucket{}
+	} else {
+		for range n {
+			b.cur = (b.cur + 1) % buckets
+			b.win[b.cur] = bucket{}
+		}
+	}
+	b.curStart = b.curStart.Add(time.Duration(n) * width)
+}
+
+func (b *Breaker) counts() (req, fail uint64) {
+	for _, bk := range b.win {
+		req += bk.requests
+		fail += bk.failures
+	}
+	return
+}
+
+func NewGroup(o Options) *Group {
+	return &Group{o: o, m: map[string]*Breaker{}}
+}
+
+// 取得名为 name 的熔断器，不存在时创建
+func (g *Group) Get(name string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.m[name]
+	if !ok {
+		b = New(name, g.o)
+		g.m[name] = b
+	}
+	return b
+}
+
+// 所有熔断器的状态，按名称排序
+func (g *Group) Stats() []Stat {
+	g.mu.Lock()
+	bs := make([]*Breaker, 0, len(g.m))
+	for _, b := range g.m {
+		bs = append(bs, b)
+	}
+	g.mu.Unlock()
+	ss := make([]Stat, len(bs))
+	for i, b := range bs {
+		ss[i] = b.Stat()
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		return ss[i].Name < ss[j].Name
+	})
+	return ss
+}
+
+// 重置名为 name 的熔断器，不存在时返回 false
+func (g *Group) Reset(name string) bool {
+	g.mu.Lock()
+	b, ok := g.m[name]
+	g.mu.Unlock()
+	if ok {
+		b.Reset()
+	}
+	return ok
+}
@@ -0,0 +1,104 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	var changes []State
+	b := New("x", Options{MinRequests: 4, FailureRatio: 0.5, OpenTimeout: 20 * time.Millisecond, HalfOpenMax: 2,
+		OnStateChange: func(_ string, _, to State) { changes = append(changes, to) }})
+	report := func(ok bool) error {
+		done, e := b.Allow()
+		if e == nil {
+			done(ok)
+		}
+		return e
+	}
+	for _, ok := range []bool{true, false, true, false} {
+		if e := report(ok); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if b.State() != Open {
+		t.Fatalf("state = %v, want open", b.State())
+	}
+	if e := report(true); !errors.Is(e, ErrOpen) {
+		t.Fatalf("want ErrOpen, got %v", e)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	d1, e1 := b.Allow()
+	d2, e2 := b.Allow()
+	if e1 != nil || e2 != nil || b.State() != HalfOpen {
+		t.Fatalf("half-open trials: %v %v %v", e1, e2, b.State())
+	}
+	if _, e := b.Allow(); !errors.Is(e, ErrOpen) {
+		t.Fatalf("third trial should be rejected, got %v", e)
+	}
+	d1(true)
+	d2(true)
+	if b.State() != Closed {
+		t.Fatalf("state = %v, want closed", b.State())
+	}
+	want := []State{Open, HalfOpen, Closed}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes = %v", changes)
+		}
+	}
+}
+
+func TestHalfOpenFailure(t *testing.T) {
+	b := New("x", Options{MinRequests: 1, OpenTimeout: 10 * time.Millisecond})
+	done, _ := b.Allow()
+	done(false)
+	time.Sleep(15 * time.Millisecond)
+	done, e := b.Allow()
+	if e != nil {
+		t.Fatal(e)
+	}
+	done(false)
+	if b.State() != Open {
+		t.Fatalf("state = %v, want open", b.State())
+	}
+	if s := b.Stat(); s.Rejected != 0 {
+		t.Fatalf("rejected = %d", s.Rejected)
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	b := New("x", Options{MinRequests: 1, OpenTimeout: 10 * time.Millisecond})
+	_, ignore, _ := b.AllowOrIgnore()
+	ignore()
+	if s := b.Stat(); s.State != Closed || s.Requests != 0 {
+		t.Fatalf("ignored request counted: %+v", s)
+	}
+	done, _ := b.Allow()
+	done(false)
+	time.Sleep(15 * time.Millisecond)
+
+	// 忽略的试探请求释放名额，不改变状态
+	done, ignore, e := b.AllowOrIgnore()
+	if e != nil {
+		t.Fatal(e)
+	}
+	ignore()
+	done(true) // 已调用 ignore，不再生效
+	if b.State() != HalfOpen {
+		t.Fatalf("state = %v, want half-open", b.State())
+	}
+	done, e = b.Allow()
+	if e != nil {
+		t.Fatal("trial slot not released:", e)
+	}
+	done(true)
+	if b.State() != Closed {
+		t.Fatalf("state = %v, want closed", b.State())
+	}
+}
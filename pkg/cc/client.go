@@ -0,0 +1,397 @@
+package cc
+
+/**
+对外请求的客户端
+
+	c := cc.NewClient(cc.ClientOptions{Timeout: 5 * time.Second, Retries: 3, UseConfigProxy: true})
+	e := c.GetJ(ctx, u, &resp)
+	e = c.PostJ(ctx, u, body, &resp, cc.WithSignature("billing", secret))
+
+- 同一个 Client 共用连接池，应长期复用而不是每次请求创建
+- Timeout 为每次尝试的超时，ctx 控制包括重试在内的总时长
+- GET、HEAD、OPTIONS、PUT、DELETE 以及带有 Idempotency-Key 的请求在连接错误、429、502、503、504 时
+  按指数退避加随机抖动重试，响应带有 Retry-After 时以其为准
+- 默认校验 TLS 证书；状态码 >= 400 时返回 *StatusError（包级的 Get、GetJ、PostJ 等旧接口除外）
+
+Get、GetJ、PostJ、GetByProxy、PostByProxy 均经由 DefaultClient（或按代理地址复用的 Client）发出，并按 host 熔断：
+某个 host 在窗口内失败（连接错误、超时或 5xx，调用方取消的请求除外）比例过高时，之后的请求直接返回 breaker.ErrOpen，
+不再占用 handler 等待超时
+
+	cc.DefaultClient.Breakers = breaker.NewGroup(breaker.Options{FailureRatio: 0.3, OpenTimeout: time.Minute})
+
+熔断器的状态可通过命令行 breakers 查看、breaker-reset <host> 重置，
+或注册 cc.BreakerStats 作为接口：a.GET("/breakers", cc.BreakerStats)
+*/
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/breaker"
+	"github.com/cyf-gh/ccgo/pkg/cc/cli"
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+)
+
+type (
+	ClientOptions struct {
+		// 每次尝试的超时，默认 15 秒
+		Timeout time.Duration
+		// 建立连接的超时，默认 5 秒
+		DialTimeout time.Duration
+		// 代理地址，例如 http://127.0.0.1:1080
+		Proxy string
+		// Proxy 为空时使用 server.cfg 的 [common] proxy（为空则不使用代理）
+		UseConfigProxy bool
+		// 跳过 TLS 证书校验，仅用于测试
+		InsecureSkipVerify bool
+		// 每个 host 保留的空闲连接数，默认 16
+		MaxIdleConnsPerHost int
+		// 最大重试次数，默认 2；< 0 为不重试
+		Retries int
+		// 第一次重试前的等待，之后每次翻倍，默认 100 毫秒
+		RetryBase time.Duration
+		// 重试等待的上限，默认 2 秒
+		RetryMax time.Duration
+		// 熔断器，默认 breaker.Options{}；需要关闭时将 Client.Breakers 置为 nil
+		Breaker *breaker.Options
+	}
+
+	Client struct {
+		HTTP *http.Client
+		// 按 host 区分的熔断器，nil 为不熔断
+		Breakers *breaker.Group
+		// 判断请求是否失败，默认为出错或状态码 >= 500；调用方取消的请求（context.Canceled）不计入统计
+		IsFailure func(resp *http.Response, e error) bool
+		// 最大重试次数，0 为不重试
+		Retries   int
+		RetryBase time.Duration
+		RetryMax  time.Duration
+		// 读取响应 body 的上限，默认 32MB
+		MaxResponseBody int64
+	}
+
+	// 响应状态码 >= 400
+	StatusError struct {
+		Code int
+		Body string
+	}
+)
+
+var (
+	DefaultClient = NewClient(ClientOptions{})
+
+	// GetByProxy、PostByProxy 按代理地址复用的 Client
+	proxyClients sync.Map // string -> *Client
+
+	ErrResponseTooLarge = errors.New("cc: response body too large")
+)
+
+func init() {
+	cli.Register("breakers", &cli.CliFuncPack{F: func(args []string) error {
+		for _, s := range DefaultClient.Breakers.Stats() {
+			fmt.Printf("%-32s %-9s requests=%d failures=%d rejected=%d since=%s\n",
+				s.Name, s.State, s.Requests, s.Failures, s.Rejected, s.Since.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	}, Desc: "Show circuit breakers of outbound requests", Group: "client"})
+	cli.Register("breaker-reset", &cli.CliFuncPack{F: func(args []string) error {
+		if len(args) < 1 || args[0] == "" {
+			return fmt.Errorf("usage: breaker-reset <host>")
+		}
+		if !DefaultClient.Breakers.Reset(args[0]) {
+			return fmt.Errorf("no breaker: %s", args[0])
+		}
+		println("reset: " + args[0])
+		return nil
+	}, Desc: "Close the circuit breaker of a host: <host>", Group: "client"})
+}
+
+func NewClient(o ClientOptions) *Client {
+	if o.Timeout <= 0 {
+		o.Timeout = 15 * time.Second
+	}
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = 5 * time.Second
+	}
+	if o.MaxIdleConnsPerHost <= 0 {
+		o.MaxIdleConnsPerHost = 16
+	}
+	if o.Retries == 0 {
+		o.Retries = 2
+	}
+	if o.Breaker == nil {
+		o.Breaker = &breaker.Options{}
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = (&net.Dialer{Timeout: o.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	tr.TLSHandshakeTimeout = o.DialTimeout
+	tr.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	tr.Proxy = proxyFunc(o)
+	if o.InsecureSkipVerify {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &Client{
+		HTTP:      &http.Client{Transport: tr, Timeout: o.Timeout},
+		Breakers:  breaker.NewGroup(*o.Breaker),
+		Retries:   max(o.Retries, 0),
+		RetryBase: o.RetryBase,
+		RetryMax:  o.RetryMax,
+	}
+}
+
+func proxyFunc(o ClientOptions) func(*http.Request) (*url.URL, error) {
+	if o.Proxy != "" {
+		u, e := url.Parse(o.Proxy)
+		return func(*http.Request) (*url.URL, error) {
+			return u, e
+		}
+	}
+	if o.UseConfigProxy {
+		// 配置在启动时才加载，因此每次请求时读取
+		return func(*http.Request) (*url.URL, error) {
+			if config.ProxyAddr == "" {
+				return nil, nil
+			}
+			return url.Parse(config.ProxyAddr)
+		}
+	}
+	return http.ProxyFromEnvironment
+}
+
+// 使用代理 proxyUrl 的 Client，按地址复用并与 DefaultClient 共用熔断器
+func proxyClient(proxyUrl string) *Client {
+	if c, ok := proxyClients.Load(proxyUrl); ok {
+		return c.(*Client)
+	}
+	c := NewClient(ClientOptions{Proxy: proxyUrl})
+	c.Breakers = DefaultClient.Breakers
+	actual, _ := proxyClients.LoadOrStore(proxyUrl, c)
+	return actual.(*Client)
+}
+
+func (se *StatusError) Error() string {
+	b := se.Body
+	if len(b) > 256 {
+		b = b[:256] + "..."
+	}
+	return "cc: unexpected status " + strconv.Itoa(se.Code) + ": " + b
+}
+
+// 发出请求，可重试的请求在失败时重试，熔断时返回 breaker.ErrOpen
+// 重试时通过 req.GetBody 重新取得 body，http.NewRequest 以 bytes.Buffer 等创建的请求会自动设置
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	first := true
+	return c.retry(req.Context(), func() (*http.Request, error) {
+		if first {
+			first = false
+			return req, nil
+		}
+		r := req.Clone(req.Context())
+		if req.GetBody != nil {
+			b, e := req.GetBody()
+			if e != nil {
+				return nil, e
+			}
+			r.Body = b
+		}
+		return r, nil
+	}, req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+}
+
+// 返回 body 的字符串
+func (c *Client) Get(ctx context.Context, u string, opts ...RequestOption) (string, error) {
+	b, e := c.send(ctx, http.MethodGet, u, nil, opts)
+	return string(b), e
+}
+
+// 将 json 响应解析到 v
+func (c *Client) GetJ(ctx context.Context, u string, v interface{}, opts ...RequestOption) error {
+	b, e := c.send(ctx, http.MethodGet, u, nil, opts)
+	return decodeJ(b, e, v)
+}
+
+// 以 json 发送 body，并将 json 响应解析到 v
+func (c *Client) PostJ(ctx context.Context, u string, body interface{}, v interface{}, opts ...RequestOption) error {
+	jn, e := json.Marshal(body)
+	if e != nil {
+		return e
+	}
+	b, e := c.send(ctx, http.MethodPost, u, jn, opts)
+	return decodeJ(b, e, v)
+}
+
+// 状态码 >= 400 时仍尝试解析 body，并返回 *StatusError
+func decodeJ(b []byte, e error, v interface{}) error {
+	var se *StatusError
+	if e != nil && !errors.As(e, &se) {
+		return e
+	}
+	if de := json.Unmarshal(b, v); de != nil && se == nil {
+		return de
+	}
+	return e
+}
+
+// 每次尝试都重新创建请求并调用 opts，例如 WithSignature 每次使用新的 nonce
+func (c *Client) send(ctx context.Context, method, u string, body []byte, opts []RequestOption) ([]byte, error) {
+	resp, e := c.retry(ctx, func() (*http.Request, error) {
+		var rd io.Reader
+		if body != nil {
+			rd = bytes.NewReader(body)
+		}
+		req, e := http.NewRequestWithContext(ctx, method, u, rd)
+		if e != nil {
+			return nil, e
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if e = applyOptions(req, opts); e != nil {
+			return nil, e
+		}
+		return req, nil
+	}, true)
+	if e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+	limit := c.MaxResponseBody
+	if limit <= 0 {
+		limit = 32 << 20
+	}
+	b, e := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if e != nil {
+		return nil, e
+	}
+	if int64(len(b)) > limit {
+		return nil, ErrResponseTooLarge
+	}
+	if resp.StatusCode >= 400 {
+		return b, &StatusError{Code: resp.StatusCode, Body: string(b)}
+	}
+	return b, nil
+}
+
+func (c *Client) retry(ctx context.Context, newReq func() (*http.Request, error), replayable bool) (*http.Response, error) {
+	retries := 0
+	for attempt := 0; ; attempt++ {
+		req, e := newReq()
+		if e != nil {
+			return nil, e
+		}
+		if attempt == 0 && replayable && idempotent(req.Method, req.Header) {
+			retries = c.Retries
+		}
+		resp, e := c.attempt(req)
+		if attempt >= retries || !shouldRetry(ctx, resp, e) {
+			return resp, e
+		}
+		d := c.backoff(attempt, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 经过熔断器发出一次请求
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	if c.Breakers == nil {
+		return hc.Do(req)
+	}
+	done, ignore, e := c.Breakers.Get(req.URL.Host).AllowOrIgnore()
+	if e != nil {
+		return nil, fmt.Errorf("%s: %w", req.URL.Host, e)
+	}
+	resp, e := hc.Do(req)
+	if e != nil && errors.Is(e, context.Canceled) {
+		// 调用方取消请求与上游是否可用无关，不计入统计
+		ignore()
+		return resp, e
+	}
+	failed := c.IsFailure
+	if failed == nil {
+		failed = isFailure
+	}
+	done(!failed(resp, e))
+	return resp, e
+}
+
+func isFailure(resp *http.Response, e error) bool {
+	return e != nil || resp.StatusCode >= 500
+}
+
+func idempotent(method string, h http.Header) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return h.Get(HeaderIdempotencyKey) != ""
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, e error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if e != nil {
+		// 证书错误重试也不会成功
+		var ce *tls.CertificateVerificationError
+		return !errors.Is(e, breaker.ErrOpen) && !errors.As(e, &ce)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// 指数退避，在 [d/2, d) 中随机取值；Retry-After 不超过 RetryMax 时以其为准
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	base, top := c.RetryBase, c.RetryMax
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if top <= 0 {
+		top = 2 * time.Second
+	}
+	if resp != nil {
+		if s, e := strconv.Atoi(resp.Header.Get("Retry-After")); e == nil && s >= 0 {
+			if d := time.Duration(s) * time.Second; d <= top {
+				return d
+			}
+		}
+	}
+	d := base << min(attempt, 16)
+	if d <= 0 || d > top {
+		d = top
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// 以 json 返回 DefaultClient 的熔断器状态，可注册为接口
+func BreakerStats(ap ActionPackage) (HttpErrReturn, StatusCode) {
+	return HerOkWithData(DefaultClient.Breakers.Stats())
+}
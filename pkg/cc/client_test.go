@@ -0,0 +1,318 @@
+package cc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/breaker"
+)
+
+// 连续 5xx 后熔断，之后的请求不再到达上游
+func TestClientBreaker(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/ok" {
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := NewClient(ClientOptions{Retries: -1, Breaker: &breaker.Options{MinRequests: 3, FailureRatio: 0.5, OpenTimeout: 50 * time.Millisecond}})
+	for i := 0; i < 3; i++ {
+		var se *StatusError
+		if _, e := c.Get(context.Background(), srv.URL+"/fail"); !errors.As(e, &se) || se.Code != http.StatusInternalServerError {
+			t.Fatal(i, e)
+		}
+	}
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if s := c.Breakers.Get(host).State(); s != breaker.Open {
+		t.Fatal("state", s)
+	}
+	if _, e := c.Get(context.Background(), srv.URL+"/ok"); !errors.Is(e, breaker.ErrOpen) || hits.Load() != 3 {
+		t.Fatal(e, hits.Load())
+	}
+
+	// 半开后试探成功则关闭
+	time.Sleep(60 * time.Millisecond)
+	if _, e := c.Get(context.Background(), srv.URL+"/ok"); e != nil {
+		t.Fatal(e)
+	}
+	if s := c.Breakers.Get(host).State(); s != breaker.Closed {
+		t.Fatal("state", s)
+	}
+
+	// 熔断器按 host 区分
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+	for i := 0; i < 3; i++ {
+		_, _ = c.Get(context.Background(), srv.URL+"/fail")
+	}
+	if _, e := c.Get(context.Background(), other.URL); e != nil {
+		t.Fatal("other host:", e)
+	}
+}
+
+// 调用方取消请求不计为失败，超时计为失败
+func TestClientBreakerCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	c := NewClient(ClientOptions{Retries: -1, Breaker: &breaker.Options{MinRequests: 2, FailureRatio: 0.5}})
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if _, e := c.Get(ctx, srv.URL); !errors.Is(e, context.Canceled) {
+			t.Fatal(e)
+		}
+	}
+	if s := c.Breakers.Get(host).Stat(); s.State != breaker.Closed || s.Failures != 0 {
+		t.Fatalf("canceled requests counted: %+v", s)
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, e := c.Get(ctx, srv.URL)
+		cancel()
+		if !errors.Is(e, context.DeadlineExceeded) {
+			t.Fatal(e)
+		}
+	}
+	if s := c.Breakers.Get(host).State(); s != breaker.Open {
+		t.Fatal("timeouts should open the breaker:", s)
+	}
+}
+
+// Breakers 为 nil 时不熔断
+func TestClientNoBreaker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := NewClient(ClientOptions{Retries: -1, Breaker: &breaker.Options{MinRequests: 1}})
+	c.Breakers = nil
+	for i := 0; i < 5; i++ {
+		if _, e := c.Get(context.Background(), srv.URL); errors.Is(e, breaker.ErrOpen) {
+			t.Fatal(i, e)
+		}
+	}
+}
+
+// 依次返回 codes 中的状态码，之后返回 200；记录每次请求的 body
+func flakyServer(codes ...int) (*httptest.Server, *[]string) {
+	var (
+		i      atomic.Int32
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if n := int(i.Add(1)) - 1; n < len(codes) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(codes[n])
+		}
+		_, _ = w.Write([]byte(`{"n":1}`))
+	}))
+	return srv, &bodies
+}
+
+func TestClientRetry(t *testing.T) {
+	c := NewClient(ClientOptions{Retries: 2, RetryBase: time.Millisecond, RetryMax: 5 * time.Millisecond})
+	idem := func(r *http.Request) error {
+		r.Header.Set(HeaderIdempotencyKey, "k")
+		return nil
+	}
+	cases := []struct {
+		name    string
+		codes   []int
+		post    bool
+		opts    []RequestOption
+		want    int // 请求次数
+		wantErr bool
+	}{
+		{"get 503", []int{503, 502}, false, nil, 3, false},
+		{"get 429 504", []int{429, 504, 503}, false, nil, 3, true},
+		{"get 500", []int{500}, false, nil, 1, true},
+		{"get 404", []int{404}, false, nil, 1, true},
+		{"post 503", []int{503}, true, nil, 1, true},
+		{"post idempotency key", []int{503}, true, []RequestOption{idem}, 2, false},
+	}
+	for _, cs := range cases {
+		srv, bodies := flakyServer(cs.codes...)
+		var (
+			v struct{ N int }
+			e error
+		)
+		if cs.post {
+			e = c.PostJ(context.Background(), srv.URL, H{"a": 1}, &v, cs.opts...)
+		} else {
+			e = c.GetJ(context.Background(), srv.URL, &v, cs.opts...)
+		}
+		srv.Close()
+		if len(*bodies) != cs.want || (e != nil) != cs.wantErr {
+			t.Errorf("%s: %d requests, err %v", cs.name, len(*bodies), e)
+		}
+		// 每次重试重新发送完整的 body
+		for _, b := range *bodies {
+			if cs.post && b != `{"a":1}` {
+				t.Errorf("%s: body %q", cs.name, b)
+			}
+		}
+	}
+
+	// 不重试
+	srv, bodies := flakyServer(503)
+	defer srv.Close()
+	nc := NewClient(ClientOptions{Retries: -1})
+	if _, e := nc.Get(context.Background(), srv.URL); e == nil || len(*bodies) != 1 {
+		t.Error("Retries < 0:", len(*bodies), e)
+	}
+}
+
+// RequestOption 在每次尝试时重新调用
+func TestClientRetryOptions(t *testing.T) {
+	srv, _ := flakyServer(503, 503)
+	defer srv.Close()
+	var calls int
+	opt := func(r *http.Request) error {
+		calls++
+		return nil
+	}
+	c := NewClient(ClientOptions{RetryBase: time.Millisecond})
+	if _, e := c.Get(context.Background(), srv.URL, opt); e != nil || calls != 3 {
+		t.Fatal(calls, e)
+	}
+}
+
+// Do 通过 GetBody 重放 body；无法重放的 body 不重试
+func TestClientDoReplay(t *testing.T) {
+	c := NewClient(ClientOptions{RetryBase: time.Millisecond})
+
+	srv, bodies := flakyServer(503)
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, bytes.NewBufferString("payload"))
+	resp, e := c.Do(req)
+	if e != nil || resp.StatusCode != http.StatusOK {
+		t.Fatal(resp, e)
+	}
+	resp.Body.Close()
+	srv.Close()
+	if len(*bodies) != 2 || (*bodies)[0] != "payload" || (*bodies)[1] != "payload" {
+		t.Fatalf("%q", *bodies)
+	}
+
+	srv, bodies = flakyServer(503)
+	defer srv.Close()
+	req, _ = http.NewRequest(http.MethodPut, srv.URL, io.NopCloser(bytes.NewBufferString("payload")))
+	resp, e = c.Do(req)
+	if e != nil || resp.StatusCode != http.StatusServiceUnavailable || len(*bodies) != 1 {
+		t.Fatal(len(*bodies), resp, e)
+	}
+	resp.Body.Close()
+}
+
+// ctx 结束后不再等待重试
+func TestClientRetryContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c := NewClient(ClientOptions{Retries: 10, RetryBase: time.Second, RetryMax: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	st := time.Now()
+	if _, e := c.Get(ctx, srv.URL); !errors.Is(e, context.DeadlineExceeded) || time.Since(st) > 500*time.Millisecond {
+		t.Fatal(e, time.Since(st))
+	}
+}
+
+func TestClientBackoff(t *testing.T) {
+	c := &Client{RetryBase: 10 * time.Millisecond, RetryMax: 100 * time.Millisecond}
+	for attempt, d := range []time.Duration{10, 20, 40, 80, 100, 100} {
+		d *= time.Millisecond
+		if attempt == 5 {
+			attempt = 100 // 移位溢出时取上限
+		}
+		for i := 0; i < 50; i++ {
+			if got := c.backoff(attempt, nil); got < d/2 || got > d {
+				t.Fatalf("attempt %d: %v not in [%v, %v]", attempt, got, d/2, d)
+			}
+		}
+	}
+
+	retryAfter := func(v string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": {v}}}
+	}
+	c.RetryMax = 3 * time.Second
+	if d := c.backoff(0, retryAfter("2")); d != 2*time.Second {
+		t.Error("Retry-After:", d)
+	}
+	// 超过 RetryMax 或无法解析时按指数退避
+	for _, v := range []string{"10", "-1", "soon"} {
+		if d := c.backoff(0, retryAfter(v)); d > 10*time.Millisecond {
+			t.Errorf("Retry-After %q: %v", v, d)
+		}
+	}
+
+	// 默认值
+	if d := (&Client{}).backoff(20, nil); d < time.Second || d > 2*time.Second {
+		t.Error("default:", d)
+	}
+}
+
+// 按代理地址复用 Client，并与 DefaultClient 共用熔断器
+func TestProxyClient(t *testing.T) {
+	a, b := proxyClient("http://127.0.0.1:1"), proxyClient("http://127.0.0.1:1")
+	if a != b || a == proxyClient("http://127.0.0.1:2") {
+		t.Fatal("proxy clients not cached by address")
+	}
+	if a.Breakers != DefaultClient.Breakers {
+		t.Fatal("breakers not shared")
+	}
+	u, e := a.HTTP.Transport.(*http.Transport).Proxy(httptest.NewRequest(http.MethodGet, "http://x", nil))
+	if e != nil || u.String() != "http://127.0.0.1:1" {
+		t.Fatal(u, e)
+	}
+}
+
+// 包级的旧接口不区分状态码
+func TestLegacyStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"n":2}`))
+	}))
+	defer srv.Close()
+
+	if s, e := Get(srv.URL); e != nil || s != `{"n":2}` {
+		t.Error("Get:", s, e)
+	}
+	var v struct{ N int }
+	if e := GetJ(srv.URL, &v); e != nil || v.N != 2 {
+		t.Error("GetJ:", v, e)
+	}
+	v.N = 0
+	if e := PostJ(srv.URL, nil, &v); e != nil || v.N != 2 {
+		t.Error("PostJ:", v, e)
+	}
+	// 解析失败仍返回错误
+	if e := GetJ(srv.URL+"/x", new(int)); e == nil {
+		t.Error("GetJ should fail to unmarshal")
+	}
+
+	var se *StatusError
+	if _, e := DefaultClient.Get(context.Background(), srv.URL); !errors.As(e, &se) || se.Code != http.StatusNotFound {
+		t.Error("Client.Get:", e)
+	}
+}
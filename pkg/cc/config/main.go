@@ -30,13 +30,42 @@ var (
 	VPTemplatePath   string
 	VPTmpPath        string
 	V1X1SrcPath      string
+	MaxBodySize      int64  = 32 << 20 // 请求 body 上限，单位字节；<= 0 为不限制
+	MaxPooledBufSize int64  = 1 << 20  // 超过该容量的池化 buffer 不再放回池中
+	CSRFSecret       string            // CSRF token 签名密钥，为空则每次启动随机生成
+	SessionSecret    string            // session cookie 签名与加密密钥，为空则每次启动随机生成
+	SecureHeaders    = SecureHeadersConfig{
+		HSTSMaxAge:            180 * 24 * time.Hour,
+		HSTSIncludeSubDomains: true,
+		CSP:                   "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+		NoSniff:               true,
+	}
 )
 
 type RedisConfig struct {
 	Addr               string
+	Password           string
+	DB                 int
 	MaxIdle, MaxActive int
 }
 
+// 安全响应头，为空的字段不发送
+type SecureHeadersConfig struct {
+	HSTSMaxAge            time.Duration // 仅对 HTTPS 请求发送，0 则不发送
+	HSTSIncludeSubDomains bool
+	HSTSPreload           bool
+	CSP                   string
+	CSPReportOnly         bool   // 以 Content-Security-Policy-Report-Only 发送，只上报不拦截
+	CSPReportURI          string // 追加 report-uri 指令
+	FrameOptions          string
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+	NoSniff               bool // X-Content-Type-Options: nosniff
+}
+
 func IsRunModeDev() bool {
 	return RunMode == "dev"
 }
@@ -57,6 +86,14 @@ func GetSthInMainDir(path string) string {
 	return dir
 }
 
+// 键存在时（包括空值）使用配置的值，空值用于关闭默认项
+func stringOr(s *ini.Section, key, def string) string {
+	if s.HasKey(key) {
+		return s.Key(key).String()
+	}
+	return def
+}
+
 func configServerInfo() {
 	var (
 		cfg *ini.File
@@ -84,6 +121,8 @@ func configServerInfo() {
 	RedisCfg.Addr = cfg.Section("redis").Key("address").String()
 	RedisCfg.MaxIdle, _ = cfg.Section("redis").Key("max_idle").Int()
 	RedisCfg.MaxActive, _ = cfg.Section("redis").Key("max_active").Int()
+	RedisCfg.Password = cfg.Section("redis").Key("password").String()
+	RedisCfg.DB, _ = cfg.Section("redis").Key("db").Int()
 
 	RunMode = cfg.Section("common").Key("mode").String()
 	V1X1SrcPath = cfg.Section("common").Key("v1x1_path").String()
@@ -93,6 +132,24 @@ func configServerInfo() {
 
 	SqlitePath = cfg.Section("sqlite3").Key("path").String()
 
+	MaxBodySize = cfg.Section("http").Key("max_body_size").MustInt64(MaxBodySize)
+	MaxPooledBufSize = cfg.Section("http").Key("max_pooled_buf_size").MustInt64(MaxPooledBufSize)
+
+	CSRFSecret = cfg.Section("csrf").Key("secret").String()
+	SessionSecret = cfg.Section("session").Key("secret").String()
+
+	sh := cfg.Section("security_headers")
+	SecureHeaders.HSTSMaxAge = time.Duration(sh.Key("hsts_max_age").MustInt64(int64(SecureHeaders.HSTSMaxAge.Seconds()))) * time.Second
+	SecureHeaders.HSTSIncludeSubDomains = sh.Key("hsts_include_subdomains").MustBool(SecureHeaders.HSTSIncludeSubDomains)
+	SecureHeaders.HSTSPreload = sh.Key("hsts_preload").MustBool(SecureHeaders.HSTSPreload)
+	SecureHeaders.CSP = stringOr(sh, "csp", SecureHeaders.CSP)
+	SecureHeaders.CSPReportOnly = sh.Key("csp_report_only").MustBool(SecureHeaders.CSPReportOnly)
+	SecureHeaders.CSPReportURI = stringOr(sh, "csp_report_uri", SecureHeaders.CSPReportURI)
+	SecureHeaders.FrameOptions = stringOr(sh, "frame_options", SecureHeaders.FrameOptions)
+	SecureHeaders.ReferrerPolicy = stringOr(sh, "referrer_policy", SecureHeaders.ReferrerPolicy)
+	SecureHeaders.PermissionsPolicy = stringOr(sh, "permissions_policy", SecureHeaders.PermissionsPolicy)
+	SecureHeaders.NoSniff = sh.Key("nosniff").MustBool(SecureHeaders.NoSniff)
+
 	DMGodId, _ = cfg.Section("dm_whitelist").Key("god_id").Int64()
 	DMRootPath = cfg.Section("dm_whitelist").Key("root_path").String()
 	println(" *************** DM configuration loaded... ***************")
@@ -0,0 +1,152 @@
+package cc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+
+	mw "github.com/cyf-gh/ccgo/pkg/cc/middleware"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+
+	"github.com/kpango/glg"
+)
+
+const (
+	HeaderRequestID = "X-Request-ID"
+)
+
+type (
+	ctxKey int
+	// 带有请求 ID 前缀的日志
+	Logger struct {
+		prefix string
+	}
+	// 请求内共享的可变状态
+	reqState struct {
+		mu      sync.Mutex
+		upload  *upload
+		written bool // 响应已由 ServeFile 等直接写出
+		// 读取 body 时超出上限
+		bodyTooLarge bool
+		// 已开始写出的流式响应
+		stream *Stream
+	}
+)
+
+const (
+	ctxKeyRequestID ctxKey = iota
+	ctxKeyIP
+	ctxKeyLogger
+	ctxKeyUser
+	ctxKeyState
+	ctxKeyCSRF
+	ctxKeyClaims
+	ctxKeySession
+	ctxKeyAPIKey
+	ctxKeySigner
+)
+
+// 请求范围内的 context
+// 包含请求 ID、用户、日志与 IP，路由超时与服务器关闭时会被取消
+func (R ActionPackage) Context() context.Context {
+	return R.R.Context()
+}
+
+// 为请求注入请求 ID、IP 与日志
+// 已注入则原样返回
+func withRequestScope(w http.ResponseWriter, r *http.Request) *http.Request {
+	if RequestID(r.Context()) != "" {
+		return r
+	}
+	id := r.Header.Get(HeaderRequestID)
+	if id == "" || len(id) > 64 {
+		id = newRequestID()
+	}
+	w.Header().Set(HeaderRequestID, id)
+
+	ctx := context.WithValue(r.Context(), ctxKeyRequestID, id)
+	ctx = context.WithValue(ctx, ctxKeyIP, mwu.GetIP(r))
+	ctx = context.WithValue(ctx, ctxKeyLogger, &Logger{prefix: "[" + id + "]"})
+	ctx = context.WithValue(ctx, ctxKeyState, &reqState{})
+	return r.WithContext(ctx)
+}
+
+// 未经路由注入时返回一个不共享的空状态
+func stateFrom(ctx context.Context) *reqState {
+	if st, ok := ctx.Value(ctxKeyState).(*reqState); ok {
+		return st
+	}
+	return &reqState{}
+}
+
+// 尽早注入请求上下文，使之后的中间件也能取得请求 ID 等信息
+// 未注册时由路由自行注入
+func RequestContext() mw.MiddewareFunc {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			f(w, withRequestScope(w, r))
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKeyRequestID).(string)
+	return id
+}
+
+func IPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(ctxKeyIP).(string)
+	return ip
+}
+
+// 总是返回可用的 Logger
+func LoggerFrom(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(ctxKeyLogger).(*Logger); ok {
+		return l
+	}
+	return &Logger{}
+}
+
+// 由认证中间件写入当前用户
+func WithUser(ctx context.Context, user interface{}) context.Context {
+	return context.WithValue(ctx, ctxKeyUser, user)
+}
+
+func UserFrom(ctx context.Context) interface{} {
+	return ctx.Value(ctxKeyUser)
+}
+
+func (l *Logger) args(v []interface{}) []interface{} {
+	if l.prefix == "" {
+		return v
+	}
+	return append([]interface{}{l.prefix}, v...)
+}
+
+func (l *Logger) Log(v ...interface{}) {
+	_ = glg.Log(l.args(v)...)
+}
+
+func (l *Logger) Info(v ...interface{}) {
+	_ = glg.Info(l.args(v)...)
+}
+
+func (l *Logger) Success(v ...interface{}) {
+	_ = glg.Success(l.args(v)...)
+}
+
+func (l *Logger) Warn(v ...interface{}) {
+	_ = glg.Warn(l.args(v)...)
+}
+
+func (l *Logger) Error(v ...interface{}) {
+	_ = glg.Error(l.args(v)...)
+}
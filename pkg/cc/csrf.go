@@ -0,0 +1,247 @@
+package cc
+
+/**
+CSRF 防护
+
+签名的双重提交 cookie：服务端下发 cookie，客户端在非安全方法（POST 等）的请求中
+通过请求头或表单字段原样带回，服务端校验二者一致且签名有效
+
+	mw.Register(cc.CSRF())
+
+	a.GET("/form", func(ap cc.ActionPackage) (cc.HttpErrReturn, cc.StatusCode) {
+		return cc.HerOkWithString(ap.CSRFToken()) // 或由前端读取 cookie cc_csrf
+	})
+	a.POST("/hook", hook).CSRFExempt() // 第三方回调等不经过浏览器的路由
+
+已通过 JWTAuth 或 APIKeyAuth 认证的请求不校验；仅携带 Authorization、X-API-Key 请求头而认证未通过的请求照常校验
+认证中间件可位于 CSRF 的内层或外层：携带上述请求头的请求在路由执行前（认证中间件都已执行）再判断
+*/
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+type (
+	CSRFOptions struct {
+		// 签名密钥，默认取 server.cfg 的 [csrf] secret，未配置时每次启动随机生成
+		Secret     []byte
+		CookieName string // 默认 cc_csrf
+		HeaderName string // 默认 X-CSRF-Token
+		FormField  string // 默认 csrf_token
+		MaxAge     time.Duration
+		// 允许的跨站来源（scheme://host），同源请求总是允许
+		TrustedOrigins []string
+		// 返回 true 的请求不校验
+		Exempt func(r *http.Request) bool
+	}
+
+	csrfState struct {
+		o     *CSRFOptions
+		token string
+		// 校验未通过，但请求携带认证信息，待路由执行前确认是否已认证
+		pending string
+	}
+)
+
+var (
+	csrfExempt   = map[string]bool{}
+	csrfExemptMu sync.RWMutex
+)
+
+// CSRF 校验中间件
+// 校验失败返回 403 与 ERR_SECURITY
+func CSRF(opts ...CSRFOptions) middleware.MiddewareFunc {
+	var o CSRFOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if len(o.Secret) == 0 && config.CSRFSecret != "" {
+		o.Secret = []byte(config.CSRFSecret)
+	}
+	if len(o.Secret) == 0 {
+		o.Secret = make([]byte, 32)
+		_, _ = rand.Read(o.Secret)
+	}
+	if o.CookieName == "" {
+		o.CookieName = "cc_csrf"
+	}
+	if o.HeaderName == "" {
+		o.HeaderName = "X-CSRF-Token"
+	}
+	if o.FormField == "" {
+		o.FormField = "csrf_token"
+	}
+	if o.MaxAge == 0 {
+		o.MaxAge = 12 * time.Hour
+	}
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			st := &csrfState{o: &o}
+			if c, e := r.Cookie(o.CookieName); e == nil && o.valid(c.Value) {
+				st.token = c.Value
+			}
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeyCSRF, st))
+			w.Header().Add("Vary", "Cookie")
+
+			if !csrfSafeMethod(r.Method) && !o.exempt(r) {
+				if desc := o.check(r, st.token); desc != "" {
+					switch {
+					case csrfAuthenticated(r.Context()):
+					case hasCredentialHeader(r):
+						st.pending = desc
+					default:
+						LoggerFrom(r.Context()).Warn("[CSRF]", r.URL.Path, desc)
+						her, status := HerSecurity(desc)
+						HttpReturnHER(&w, &her, status, r.URL.Path)
+						return
+					}
+				}
+			}
+			if st.token == "" {
+				st.issue(w, r)
+			}
+			f(w, r)
+		}
+	}
+}
+
+// 当前请求的 CSRF token，用于写入页面或返回给前端
+// 未启用 CSRF 中间件时返回空
+func (R ActionPackage) CSRFToken() string {
+	st, ok := R.Context().Value(ctxKeyCSRF).(*csrfState)
+	if !ok {
+		return ""
+	}
+	return st.token
+}
+
+// 重新下发 CSRF token，应在登录等改变身份的操作后调用
+func (R ActionPackage) RotateCSRFToken() string {
+	st, ok := R.Context().Value(ctxKeyCSRF).(*csrfState)
+	if !ok {
+		return ""
+	}
+	st.issue(*R.W, R.R)
+	return st.token
+}
+
+// 该路由不进行 CSRF 校验，用于 webhook 等非浏览器调用
+func (rt *Route) CSRFExempt() *Route {
+	csrfExemptMu.Lock()
+	csrfExempt[rt.Path] = true
+	csrfExemptMu.Unlock()
+	return rt
+}
+
+func (st *csrfState) issue(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	tok := base64.RawURLEncoding.EncodeToString(b)
+	st.token = tok + "." + st.o.sign(tok)
+	http.SetCookie(w, &http.Cookie{
+		Name:  st.o.CookieName,
+		Value: st.token,
+		Path:  "/",
+		// 前端需要读取 cookie 放入请求头
+		HttpOnly: false,
+		Secure:   mwu.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(st.o.MaxAge.Seconds()),
+	})
+}
+
+func (o *CSRFOptions) sign(tok string) string {
+	m := hmac.New(sha256.New, o.Secret)
+	m.Write([]byte(tok))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func (o *CSRFOptions) valid(v string) bool {
+	tok, sig, ok := strings.Cut(v, ".")
+	return ok && tok != "" && hmac.Equal([]byte(sig), []byte(o.sign(tok)))
+}
+
+func (o *CSRFOptions) exempt(r *http.Request) bool {
+	if o.Exempt != nil && o.Exempt(r) {
+		return true
+	}
+	csrfExemptMu.RLock()
+	defer csrfExemptMu.RUnlock()
+	return csrfExempt[r.Pattern] || csrfExempt[r.URL.Path]
+}
+
+// 由 JWTAuth 或 APIKeyAuth 认证的请求不依赖 cookie，跨站请求无法伪造
+func csrfAuthenticated(ctx context.Context) bool {
+	return ClaimsFrom(ctx) != nil || APIKeyFrom(ctx) != nil
+}
+
+func hasCredentialHeader(r *http.Request) bool {
+	return r.Header.Get("X-API-Key") != "" || bearerToken(r) != ""
+}
+
+// 由 Route.call 调用：认证中间件位于 CSRF 内层时，此时才能确认请求是否已认证
+func csrfVerifyPending(r *http.Request) error {
+	st, ok := r.Context().Value(ctxKeyCSRF).(*csrfState)
+	if !ok || st.pending == "" || csrfAuthenticated(r.Context()) {
+		return nil
+	}
+	LoggerFrom(r.Context()).Warn("[CSRF]", r.URL.Path, st.pending)
+	return NewHerError(http.StatusForbidden, err_code.ERR_SECURITY, st.pending)
+}
+
+// 校验通过返回空，否则返回原因
+func (o *CSRFOptions) check(r *http.Request, cookie string) string {
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "null" && !o.trustedOrigin(r, origin) {
+		return "cross-site request from " + origin
+	}
+	if cookie == "" {
+		return "csrf cookie missing"
+	}
+	sent := r.Header.Get(o.HeaderName)
+	if sent == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		// 仅读取表单，multipart 上传请使用请求头
+		sent = r.PostFormValue(o.FormField)
+	}
+	if sent == "" {
+		return "csrf token missing"
+	}
+	if subtle.ConstantTimeCompare([]byte(sent), []byte(cookie)) != 1 {
+		return "csrf token mismatch"
+	}
+	return ""
+}
+
+func (o *CSRFOptions) trustedOrigin(r *http.Request, origin string) bool {
+	u, e := url.Parse(origin)
+	if e != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, t := range o.TrustedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(t, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func csrfSafeMethod(m string) bool {
+	return m == http.MethodGet || m == http.MethodHead || m == http.MethodOptions || m == http.MethodTrace
+}
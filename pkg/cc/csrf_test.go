@@ -0,0 +1,126 @@
+package cc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/jwt"
+)
+
+func csrfRequest(method, path, cookie, token string, hdr ...string) *http.Request {
+	r := httptest.NewRequest(method, path, nil)
+	if cookie != "" {
+		r.AddCookie(&http.Cookie{Name: "cc_csrf", Value: cookie})
+	}
+	if token != "" {
+		r.Header.Set("X-CSRF-Token", token)
+	}
+	for i := 0; i+1 < len(hdr); i += 2 {
+		r.Header.Set(hdr[i], hdr[i+1])
+	}
+	return r
+}
+
+func TestCSRF(t *testing.T) {
+	a := ActionGroup{Path: "/t_csrf"}.Use(CSRF(CSRFOptions{Secret: []byte("k"), TrustedOrigins: []string{"https://ok.com"}}))
+	ok := func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOkWithString(ap.CSRFToken()) }
+	a.GET("/get", ok)
+	a.POST("/post", ok)
+	a.POST("/hook", ok).CSRFExempt()
+
+	w, _ := serve(httptest.NewRequest(http.MethodGet, "/t_csrf/get", nil))
+	var tok string
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "cc_csrf" {
+			tok = c.Value
+			if c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+				t.Errorf("cookie attributes: %+v", c)
+			}
+		}
+	}
+	if tok == "" {
+		t.Fatal("csrf cookie not issued", w.Header())
+	}
+
+	forged := tok[:strings.Index(tok, ".")] + ".AAAA"
+	form := httptest.NewRequest(http.MethodPost, "/t_csrf/post", strings.NewReader(url.Values{"csrf_token": {tok}}.Encode()))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	form.AddCookie(&http.Cookie{Name: "cc_csrf", Value: tok})
+	cases := []struct {
+		name string
+		r    *http.Request
+		pass bool
+	}{
+		{"header", csrfRequest(http.MethodPost, "/t_csrf/post", tok, tok), true},
+		{"form", form, true},
+		{"trusted origin", csrfRequest(http.MethodPost, "/t_csrf/post", tok, tok, "Origin", "https://ok.com"), true},
+		{"exempt route", csrfRequest(http.MethodPost, "/t_csrf/hook", "", ""), true},
+		{"no cookie", csrfRequest(http.MethodPost, "/t_csrf/post", "", tok), false},
+		{"no token", csrfRequest(http.MethodPost, "/t_csrf/post", tok, ""), false},
+		{"mismatch", csrfRequest(http.MethodPost, "/t_csrf/post", tok, tok+"x"), false},
+		{"forged signature", csrfRequest(http.MethodPost, "/t_csrf/post", forged, forged), false},
+		{"cross origin", csrfRequest(http.MethodPost, "/t_csrf/post", tok, tok, "Origin", "https://evil.com"), false},
+		// 未经认证中间件确认的凭证请求头不能跳过校验
+		{"unverified bearer", csrfRequest(http.MethodPost, "/t_csrf/post", "", "", "Authorization", "Bearer anything"), false},
+		{"unverified api key", csrfRequest(http.MethodPost, "/t_csrf/post", "", "", "X-API-Key", "anything"), false},
+	}
+	for _, c := range cases {
+		w, her := serve(c.r)
+		if c.pass && (w.Code != http.StatusOK || her.ErrCod != err_code.ERR_OK) {
+			t.Errorf("%s: %d %+v", c.name, w.Code, her)
+		}
+		if !c.pass && (w.Code != http.StatusForbidden || her.ErrCod != err_code.ERR_SECURITY) {
+			t.Errorf("%s: %d %+v", c.name, w.Code, her)
+		}
+	}
+}
+
+// 已认证的请求不校验，与认证中间件的先后无关
+func TestCSRFAuthenticated(t *testing.T) {
+	secret := []byte("csrf-jwt")
+	v := jwt.NewVerifier(jwt.Options{Keys: jwt.NewHMACKeySet(secret)})
+	tok, e := jwt.Sign(jwt.Claims{Subject: "u"}, jwt.HS256, "", secret)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ok := func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOk() }
+	// 后添加的中间件在外层
+	ActionGroup{Path: "/t_csrf_inner"}.Use(CSRF(), JWTAuth(v)).POST("/x", ok)
+	ActionGroup{Path: "/t_csrf_outer"}.Use(JWTAuth(v), CSRF()).POST("/x", ok)
+
+	for _, p := range []string{"/t_csrf_inner/x", "/t_csrf_outer/x"} {
+		w, her := serve(csrfRequest(http.MethodPost, p, "", "", "Authorization", "Bearer "+tok))
+		if w.Code != http.StatusOK || her.ErrCod != err_code.ERR_OK {
+			t.Errorf("%s valid jwt: %d %+v", p, w.Code, her)
+		}
+		w, _ = serve(csrfRequest(http.MethodPost, p, "", "", "Authorization", "Bearer "+tok+"x"))
+		if w.Code == http.StatusOK {
+			t.Errorf("%s invalid jwt passed", p)
+		}
+	}
+}
+
+func TestSetCookieSameSite(t *testing.T) {
+	cases := []struct {
+		in, want http.SameSite
+		secure   bool
+	}{
+		{0, http.SameSiteLaxMode, false},
+		{http.SameSiteDefaultMode, http.SameSiteDefaultMode, false},
+		{http.SameSiteStrictMode, http.SameSiteStrictMode, false},
+		{http.SameSiteNoneMode, http.SameSiteNoneMode, true},
+	}
+	for _, c := range cases {
+		var w http.ResponseWriter = httptest.NewRecorder()
+		ap := &ActionPackage{R: httptest.NewRequest(http.MethodGet, "/", nil), W: &w}
+		ck := &http.Cookie{Name: "a", Value: "b", SameSite: c.in}
+		ap.SetCookie(ck)
+		if ck.SameSite != c.want || ck.Secure != c.secure {
+			t.Errorf("SameSite %d: got %d secure=%v", c.in, ck.SameSite, ck.Secure)
+		}
+	}
+}
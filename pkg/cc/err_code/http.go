@@ -8,6 +8,12 @@ const (
 	ERR_OK = "0"		// ok
 	ERR_INVALID_ARGUMENT = "-4" // 参数错误
 	ERR_NO_AUTH = "-5"
+	ERR_TIMEOUT = "-6"		// 请求超时
+	ERR_UNAVAILABLE = "-7"	// 服务暂不可用
+	// "-8" 已被弃用路由的提示使用，见 ActionGroup.IsDeprecated
+	ERR_CONFLICT = "-9"		// 与已有的请求或资源冲突
+	ERR_NOT_FOUND = "-10"		// 资源不存在
+	ERR_TOO_MANY_REQUESTS = "-11"	// 超出频率或配额限制
 	ERR_DEPRECATED = "-1000"
 )
 
@@ -0,0 +1,138 @@
+package cc
+
+/**
+ETag 与条件请求
+
+GET、PUT、PATCH 路由成功（状态码 200 且 ErrCod 为 ERR_OK）的 HER 带有由序列化结果计算的弱 ETag，GET_DO 以 Data 计算；
+GET 请求的 If-None-Match 命中，或没有 If-None-Match 时 If-Modified-Since 不早于 ap.SetLastModified 设置的时间，返回 304
+
+乐观并发控制：
+
+	a.GET("/article", func(ap cc.ActionPackage) (cc.HttpErrReturn, cc.StatusCode) {
+		return cc.HerOkWithData(load(id))
+	})
+	a.PUT("/article", func(ap cc.ActionPackage) (cc.HttpErrReturn, cc.StatusCode) {
+		if e := ap.IfMatch(cc.ETagOf(load(id))); e != nil {
+			return cc.HerFromError(e) // 412
+		}
+		...
+	}).RequireIfMatch() // 没有 If-Match 时返回 428
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err"
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+
+	"github.com/kpango/glg"
+)
+
+// 值经 HerOkWithData 返回时的 ETag，用于与客户端的 If-Match 比较
+func ETagOf(v interface{}) string {
+	her, _ := HerOkWithData(v)
+	bs, e := json.Marshal(her)
+	err.Assert(e)
+	return etagOf(bs)
+}
+
+func etagOf(bs []byte) string {
+	sum := sha256.Sum256(bs)
+	return `W/"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// 条件不满足
+func ErrPreconditionFailed(desc string) *HerError {
+	return NewHerError(http.StatusPreconditionFailed, err_code.ERR_CONFLICT, desc)
+}
+
+// 缺少 If-Match
+func ErrPreconditionRequired() *HerError {
+	return NewHerError(http.StatusPreconditionRequired, err_code.ERR_INVALID_ARGUMENT, "If-Match header required")
+}
+
+// 设置响应的 Last-Modified，用于 If-Modified-Since 判断
+func (R ActionPackage) SetLastModified(t time.Time) {
+	if !t.IsZero() {
+		(*R.W).Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// 校验 If-Match，current 为资源当前的 ETag，例如 ETagOf(v)
+// 请求没有 If-Match 时返回 nil；比较时忽略弱标记 W/（弱比较，见 etagMatch）
+func (R ActionPackage) IfMatch(current string) error {
+	im := R.R.Header.Get("If-Match")
+	if im == "" || etagMatch(im, current) {
+		return nil
+	}
+	return ErrPreconditionFailed("resource has been modified")
+}
+
+// PUT、PATCH 等路由要求请求带有 If-Match，否则返回 428
+func (rt *Route) RequireIfMatch() *Route {
+	rt.requireIfMatch = true
+	return rt
+}
+
+// header 为逗号分隔的 ETag 列表或 *
+// 比较时忽略弱标记 W/，即 RFC 9110 的弱比较；If-Match 本应使用强比较，
+// 但这里生成的 ETag 都是弱 ETag（Compress 等中间件会改变实际传输的字节），强比较下 If-Match 永远不会满足，
+// 因此 If-Match 同样使用弱比较：ETag 相同表示 HER 的内容相同，足以用于乐观并发控制
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// 带 ETag 与条件请求处理的 HttpReturnHER
+func httpReturnHERConditional(w http.ResponseWriter, r *http.Request, her *HttpErrReturn, status StatusCode) {
+	if status != http.StatusOK || her.ErrCod != err_code.ERR_OK {
+		HttpReturnHER(&w, her, status, r.URL.Path)
+		return
+	}
+	bs, e := her.marshal()
+	err.Assert(e)
+	if conditional(w, r, bs) {
+		return
+	}
+	writeHER(&w, her, bs, status, r.URL.Path)
+}
+
+// 以响应内容 bs 设置 ETag；GET 请求的条件满足时写出 304 并返回 true
+func conditional(w http.ResponseWriter, r *http.Request, bs []byte) bool {
+	etag := etagOf(bs)
+	h := w.Header()
+	h.Set("ETag", etag)
+	if r.Method != http.MethodGet || !notModified(r, h, etag) {
+		return false
+	}
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	glg.Log(fmt.Sprintf("[HttpReturn] {%s} - StatusCode:(%d)", r.URL.Path, http.StatusNotModified))
+	return true
+}
+
+func notModified(r *http.Request, h http.Header, etag string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, etag)
+	}
+	ims, lm := r.Header.Get("If-Modified-Since"), h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	t, e1 := http.ParseTime(ims)
+	m, e2 := http.ParseTime(lm)
+	return e1 == nil && e2 == nil && !m.After(t)
+}
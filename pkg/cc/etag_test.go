@@ -0,0 +1,130 @@
+package cc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+)
+
+func TestETagMatch(t *testing.T) {
+	cases := []struct {
+		header, etag string
+		want         bool
+	}{
+		{`W/"a"`, `W/"a"`, true},
+		{`"a"`, `W/"a"`, true},
+		{`"b", W/"a"`, `W/"a"`, true},
+		{`*`, `W/"a"`, true},
+		{`"b"`, `W/"a"`, false},
+		{`W/"ab"`, `W/"a"`, false},
+	}
+	for _, c := range cases {
+		if got := etagMatch(c.header, c.etag); got != c.want {
+			t.Errorf("etagMatch(%q, %q) = %v", c.header, c.etag, got)
+		}
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	mod := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := ActionGroup{Path: "/t_etag"}
+	a.GET("/x", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		ap.SetLastModified(mod)
+		return HerOkWithData("v")
+	})
+	a.GET_DO("/do", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		return HerOkWithData([]int{1, 2})
+	})
+	a.GET("/fail", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		return HerArgInvalid("x")
+	})
+
+	get := func(path string, hdr ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(hdr); i += 2 {
+			r.Header.Set(hdr[i], hdr[i+1])
+		}
+		w, _ := serve(r)
+		return w
+	}
+	w := get("/t_etag/x")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) || etag != ETagOf("v") {
+		t.Fatalf("etag %q, ETagOf %q", etag, ETagOf("v"))
+	}
+	cases := []struct {
+		hdr  []string
+		code int
+	}{
+		{[]string{"If-None-Match", etag}, http.StatusNotModified},
+		{[]string{"If-None-Match", `"other", ` + etag}, http.StatusNotModified},
+		{[]string{"If-None-Match", `"other"`}, http.StatusOK},
+		{[]string{"If-Modified-Since", mod.Format(http.TimeFormat)}, http.StatusNotModified},
+		{[]string{"If-Modified-Since", mod.Add(-time.Second).Format(http.TimeFormat)}, http.StatusOK},
+		// If-None-Match 优先于 If-Modified-Since
+		{[]string{"If-None-Match", `"other"`, "If-Modified-Since", mod.Format(http.TimeFormat)}, http.StatusOK},
+	}
+	for _, c := range cases {
+		w := get("/t_etag/x", c.hdr...)
+		if w.Code != c.code {
+			t.Errorf("%v: %d, want %d", c.hdr, w.Code, c.code)
+		}
+		if c.code == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("ETag") != etag) {
+			t.Errorf("%v: 304 body %q etag %q", c.hdr, w.Body, w.Header().Get("ETag"))
+		}
+	}
+
+	w = get("/t_etag/do")
+	if w.Body.String() != "[1,2]" || w.Header().Get("ETag") != etagOf([]byte("[1,2]")) {
+		t.Fatalf("GET_DO: %q %v", w.Body, w.Header())
+	}
+	if w := get("/t_etag/do", "If-None-Match", w.Header().Get("ETag")); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("GET_DO conditional: %d %q", w.Code, w.Body)
+	}
+	if w := get("/t_etag/fail"); w.Header().Get("ETag") != "" {
+		t.Error("error HER has ETag")
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	cur := "v1"
+	a := ActionGroup{Path: "/t_ifmatch"}
+	update := func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		if e := ap.IfMatch(ETagOf(cur)); e != nil {
+			return HerFromError(e)
+		}
+		cur = "v2"
+		return HerOkWithData(cur)
+	}
+	a.PUT("/strict", update).RequireIfMatch()
+	a.PATCH("/loose", update)
+
+	do := func(method, path, ifMatch string) (*httptest.ResponseRecorder, HttpErrReturn) {
+		r := httptest.NewRequest(method, path, nil)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		return serve(r)
+	}
+	if w, her := do(http.MethodPut, "/t_ifmatch/strict", ""); w.Code != http.StatusPreconditionRequired || her.ErrCod != err_code.ERR_INVALID_ARGUMENT || cur != "v1" {
+		t.Errorf("missing If-Match: %d %+v", w.Code, her)
+	}
+	if w, her := do(http.MethodPut, "/t_ifmatch/strict", ETagOf("v0")); w.Code != http.StatusPreconditionFailed || her.ErrCod != err_code.ERR_CONFLICT || cur != "v1" {
+		t.Errorf("stale If-Match: %d %+v", w.Code, her)
+	}
+	w, her := do(http.MethodPut, "/t_ifmatch/strict", strings.TrimPrefix(ETagOf("v1"), "W/"))
+	if w.Code != http.StatusOK || cur != "v2" || w.Header().Get("ETag") != ETagOf("v2") {
+		t.Errorf("matching If-Match: %d %+v %v", w.Code, her, w.Header())
+	}
+	// 未设置 RequireIfMatch 时没有 If-Match 照常执行
+	if w, _ := do(http.MethodPatch, "/t_ifmatch/loose", ""); w.Code != http.StatusOK {
+		t.Errorf("PATCH without If-Match: %d", w.Code)
+	}
+	if w, _ := do(http.MethodPatch, "/t_ifmatch/loose", "*"); w.Code != http.StatusOK {
+		t.Errorf("If-Match *: %d", w.Code)
+	}
+}
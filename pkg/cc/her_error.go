@@ -0,0 +1,82 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+)
+
+type (
+	// 携带 HER 错误代码与 http 状态码的 error
+	// 由 Handle 注册的处理函数返回时会被原样转化为 HER
+	HerError struct {
+		Status StatusCode
+		ErrCod string
+		Desc   string
+		Data   string
+		Err    error // 原始错误，仅用于日志
+	}
+)
+
+func NewHerError(status StatusCode, errcode, desc string) *HerError {
+	return &HerError{Status: status, ErrCod: errcode, Desc: desc}
+}
+
+func (he *HerError) Error() string {
+	if he.Err != nil {
+		return he.Desc + ": " + he.Err.Error()
+	}
+	return he.Desc
+}
+
+func (he *HerError) Unwrap() error {
+	return he.Err
+}
+
+// 包装原始错误
+func (he *HerError) Wrap(e error) *HerError {
+	c := *he
+	c.Err = e
+	return &c
+}
+
+// 参数错误，与 HerArgInvalid 一致
+func ErrArgInvalid(argName string) *HerError {
+	return NewHerError(http.StatusOK, err_code.ERR_INVALID_ARGUMENT, "invalid argument: \""+argName+"\"")
+}
+
+// 未授权
+func ErrNoAuth(desc string) *HerError {
+	return NewHerError(http.StatusUnauthorized, err_code.ERR_NO_AUTH, desc)
+}
+
+// 资源不存在
+func ErrNotFound(desc string) *HerError {
+	return NewHerError(http.StatusNotFound, err_code.ERR_NOT_FOUND, desc)
+}
+
+// 将 error 转化为 HER
+// HerError 原样返回；FieldErrors 见 HerArgsInvalid；body 超出上限见 HerBodyTooLarge；context 超时见 HerTimeout；其余视为系统错误
+func HerFromError(e error) (HttpErrReturn, StatusCode) {
+	var (
+		he  *HerError
+		fes FieldErrors
+	)
+	switch {
+	case errors.As(e, &he):
+		her := MakeHER(he.Desc, he.ErrCod)
+		her.Data = he.Data
+		return *her, he.Status
+	case errors.As(e, &fes):
+		return HerArgsInvalid(fes)
+	case IsBodyTooLarge(e):
+		return HerBodyTooLarge()
+	case errors.Is(e, context.DeadlineExceeded):
+		return HerTimeout()
+	case errors.Is(e, context.Canceled) && IsShuttingDown():
+		return HerServiceUnavailable("server shutting down")
+	}
+	return *MakeHER(e.Error(), err_code.ERR_SYS), http.StatusInternalServerError
+}
@@ -0,0 +1,74 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+)
+
+func TestHerFromError(t *testing.T) {
+	cases := []struct {
+		e      error
+		code   string
+		status StatusCode
+	}{
+		{ErrNotFound("no such user"), err_code.ERR_NOT_FOUND, http.StatusNotFound},
+		{fmt.Errorf("load: %w", ErrNoAuth("login required")), err_code.ERR_NO_AUTH, http.StatusUnauthorized},
+		{FieldErrors{{Field: "name", Source: BindBody, Reason: "required"}}, err_code.ERR_INVALID_ARGUMENT, http.StatusOK},
+		{&http.MaxBytesError{Limit: 1}, err_code.ERR_INVALID_ARGUMENT, http.StatusRequestEntityTooLarge},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), err_code.ERR_TIMEOUT, http.StatusServiceUnavailable},
+		{errors.New("boom"), err_code.ERR_SYS, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		her, status := HerFromError(c.e)
+		if her.ErrCod != c.code || status != c.status {
+			t.Fatal(c.e, her, status)
+		}
+	}
+
+	he := ErrNotFound("gone").Wrap(errors.New("sql: no rows"))
+	if her, _ := HerFromError(he); her.Desc != "gone" || !strings.Contains(he.Error(), "sql: no rows") {
+		t.Fatal(her, he)
+	}
+}
+
+type handleReq struct {
+	Name string `query:"name" validate:"required"`
+}
+
+func TestHandle(t *testing.T) {
+	var f ActionFunc
+	reg := func(_ string, af ActionFunc) *Route {
+		f = af
+		return &Route{}
+	}
+	rt := Handle(reg, "/hello", func(_ context.Context, req handleReq) (string, error) {
+		if req.Name == "nobody" {
+			return "", ErrNotFound("no such user")
+		}
+		return "hello " + req.Name, nil
+	})
+	if rt.ReqType.Name() != "handleReq" || rt.RespType.Kind().String() != "string" {
+		t.Fatal(rt.ReqType, rt.RespType)
+	}
+
+	call := func(url string) (HttpErrReturn, StatusCode) {
+		var w http.ResponseWriter = httptest.NewRecorder()
+		return f(ActionPackage{R: httptest.NewRequest(http.MethodGet, url, nil), W: &w})
+	}
+	if her, status := call("/hello?name=cc"); her.ErrCod != err_code.ERR_OK || her.Data != `"hello cc"` || status != http.StatusOK {
+		t.Fatal(her, status)
+	}
+	if her, status := call("/hello"); her.ErrCod != err_code.ERR_INVALID_ARGUMENT || status != http.StatusOK {
+		t.Fatal(her, status)
+	}
+	if her, status := call("/hello?name=nobody"); her.ErrCod != err_code.ERR_NOT_FOUND || status != http.StatusNotFound {
+		t.Fatal(her, status)
+	}
+}
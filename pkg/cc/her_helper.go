@@ -2,7 +2,9 @@ package cc
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/cyf-gh/ccgo/pkg/cc/err"
@@ -71,6 +73,79 @@ func HerArgInvalid(argName string) (HttpErrReturn, StatusCode) {
 	}, http.StatusOK
 }
 
+// 用于返回 Bind 等产生的错误
+// e 为 FieldErrors 时 Data 中携带所有出错字段的 json 列表
+func HerArgsInvalid(e error) (HttpErrReturn, StatusCode) {
+	var fes FieldErrors
+	if IsBodyTooLarge(e) {
+		return HerBodyTooLarge()
+	}
+	if !errors.As(e, &fes) {
+		return HerArgInvalid(e.Error())
+	}
+	jn, je := json.Marshal(fes)
+	err.Assert(je)
+	return HttpErrReturn{
+		ErrCod: err_code.ERR_INVALID_ARGUMENT,
+		Desc:   "invalid argument: \"" + strings.Join(fes.Fields(), "\", \"") + "\"",
+		Data:   string(jn),
+	}, http.StatusOK
+}
+
+// 请求 body 超出上限
+func HerBodyTooLarge() (HttpErrReturn, StatusCode) {
+	return HttpErrReturn{
+		ErrCod: err_code.ERR_INVALID_ARGUMENT,
+		Desc:   "request body too large",
+		Data:   "",
+	}, http.StatusRequestEntityTooLarge
+}
+
+// 请求超时
+func HerTimeout() (HttpErrReturn, StatusCode) {
+	return HttpErrReturn{
+		ErrCod: err_code.ERR_TIMEOUT,
+		Desc:   "request timeout",
+		Data:   "",
+	}, http.StatusServiceUnavailable
+}
+
+// 服务暂不可用，例如服务器正在关闭
+func HerServiceUnavailable(desc string) (HttpErrReturn, StatusCode) {
+	return HttpErrReturn{
+		ErrCod: err_code.ERR_UNAVAILABLE,
+		Desc:   desc,
+		Data:   "",
+	}, http.StatusServiceUnavailable
+}
+
+// 超出频率或配额限制
+func HerTooManyRequests(desc string) (HttpErrReturn, StatusCode) {
+	return HttpErrReturn{
+		ErrCod: err_code.ERR_TOO_MANY_REQUESTS,
+		Desc:   desc,
+		Data:   "",
+	}, http.StatusTooManyRequests
+}
+
+// 与已有的请求或资源冲突
+func HerConflict(desc string) (HttpErrReturn, StatusCode) {
+	return HttpErrReturn{
+		ErrCod: err_code.ERR_CONFLICT,
+		Desc:   desc,
+		Data:   "",
+	}, http.StatusConflict
+}
+
+// 安全校验失败，例如 CSRF
+func HerSecurity(desc string) (HttpErrReturn, StatusCode) {
+	return HttpErrReturn{
+		ErrCod: err_code.ERR_SECURITY,
+		Desc:   desc,
+		Data:   "",
+	}, http.StatusForbidden
+}
+
 // 用于在弃用的API中直接返回
 // 请使用( a ActionGroup ) Deprecated
 func HerDeprecated() (HttpErrReturn, StatusCode) {
@@ -20,6 +20,7 @@ type (
 		ErrCod string // 内部错误代码，与http状态码不同见第四行
 		Desc   string // 错误描述
 		Data   string // 携带数据
+		raw    string // 由缓存读出时为保存的序列化结果，原样写出
 	}
 	MakeHERxxx func(desc, errcode string) (*HttpErrReturn, int)
 	StatusCode int
@@ -42,6 +43,21 @@ func MakeHER(desc, errcode string) *HttpErrReturn {
 }
 
 func HttpReturnHER(w *http.ResponseWriter, her *HttpErrReturn, statusCode StatusCode, url string) {
+	// 将her结构体转化为json
+	bs, e := her.marshal()
+	err.Assert(e)
+	writeHER(w, her, bs, statusCode, url)
+}
+
+func (her *HttpErrReturn) marshal() ([]byte, error) {
+	if her.raw != "" {
+		return []byte(her.raw), nil
+	}
+	return json.Marshal(*her)
+}
+
+// bs 为 her 序列化后的 json
+func writeHER(w *http.ResponseWriter, her *HttpErrReturn, bs []byte, statusCode StatusCode, url string) {
 	defer func() {
 		if e := recover(); e != nil {
 			glg.Error(e)
@@ -51,11 +67,7 @@ func HttpReturnHER(w *http.ResponseWriter, her *HttpErrReturn, statusCode Status
 	}()
 
 	(*w).WriteHeader(int(statusCode))
-
-	// 将her结构体转化为json
-	bs, e := json.Marshal(*her)
-	err.Assert(e)
-	_, e = (*w).Write(bs)
+	_, e := (*w).Write(bs)
 	err.Assert(e)
 
 	// 保证her长度不会爆日志
@@ -64,6 +76,7 @@ func HttpReturnHER(w *http.ResponseWriter, her *HttpErrReturn, statusCode Status
 	if len(her.Data) > 1024 {
 		her.Data = her.Data[1:1024]
 	}
+	her.raw = ""
 
 	glg.Log(fmt.Sprintf("[HttpReturn] {%s} - StatusCode:(%d) - HER (%s)", url, statusCode, her))
 }
@@ -148,6 +161,10 @@ func ErrorFetcher() middleware.MiddewareFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if r := recover(); r != nil {
+					if r == http.ErrAbortHandler {
+						// 中断连接，例如流式响应中途出错
+						panic(r)
+					}
 					glg.Warn("ErrorFetcher occurred")
 					HttpRecoverBasic(&w, r)
 				} else {
@@ -0,0 +1,260 @@
+package cc
+
+/**
+幂等请求
+
+客户端为每个业务操作生成唯一的 Idempotency-Key 并在重试时保持不变：
+
+	a = a.Use(cc.Idempotency(cc.IdempotencyOptions{Store: kv.NewRedis(config.RedisCfg)}))
+
+- 首次请求正常执行，响应（状态码、响应头、body）保存 TTL 时长
+- 相同 key 与相同请求重放保存的响应，并带有 Idempotent-Replayed: true
+- 相同 key 但请求不同，或首次请求仍在处理中，返回 409 与 ERR_CONFLICT
+- 5xx 响应不保存，客户端可用同一 key 重试
+
+key 按调用方（API key、JWT subject 或 IP）与路径隔离，因此应在认证中间件之后使用
+*/
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/kv"
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotentReplayed  = "Idempotent-Replayed"
+	idempotencyMaxKeyLen      = 255
+	idempotencyDefaultMaxSave = 1 << 20
+)
+
+// 与本次请求相关、重放时不应带回的响应头
+var idemSkipHeaders = []string{"Set-Cookie", "Date", HeaderRequestID}
+
+type (
+	IdempotencyOptions struct {
+		Store kv.Store // 默认 kv.NewMemory()；多实例部署时应使用共享的存储
+		// 响应保存时长，默认 24 小时
+		TTL time.Duration
+		// 处理中的占位记录的时长，默认 1 分钟；超过后同一 key 的请求会再次执行
+		LockTTL time.Duration
+		// 生效的方法，默认 POST、PATCH
+		Methods []string
+		// 可保存的响应 body 上限，默认 1MB；超出时不保存
+		MaxSave int
+	}
+
+	idemRecord struct {
+		Pending     bool                `json:"p,omitempty"`
+		Fingerprint string              `json:"f"`
+		Status      int                 `json:"s,omitempty"`
+		Header      map[string][]string `json:"h,omitempty"`
+		Body        []byte              `json:"b,omitempty"`
+	}
+
+	idemWriter struct {
+		http.ResponseWriter
+		max      int
+		status   int
+		buf      bytes.Buffer
+		overflow bool
+	}
+)
+
+// 幂等中间件
+func Idempotency(opts ...IdempotencyOptions) middleware.MiddewareFunc {
+	var o IdempotencyOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Store == nil {
+		o.Store = kv.NewMemory()
+	}
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.LockTTL <= 0 {
+		o.LockTTL = time.Minute
+	}
+	if len(o.Methods) == 0 {
+		o.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if o.MaxSave <= 0 {
+		o.MaxSave = idempotencyDefaultMaxSave
+	}
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderIdempotencyKey)
+			if key == "" || !slices.Contains(o.Methods, r.Method) {
+				f(w, r)
+				return
+			}
+			if len(key) > idempotencyMaxKeyLen {
+				her, status := HerArgInvalid(HeaderIdempotencyKey)
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+				return
+			}
+			fp, e := idemFingerprint(r)
+			if e != nil {
+				her, status := HerFromError(e)
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+				return
+			}
+			ctx := r.Context()
+			sk := "idem:" + principalOf(r) + ":" + r.URL.Path + ":" + key
+			pending, _ := json.Marshal(idemRecord{Pending: true, Fingerprint: fp})
+			fresh, e := o.Store.SetNX(ctx, sk, pending, o.LockTTL)
+			if e != nil {
+				// 存储不可用时不阻塞请求
+				LoggerFrom(ctx).Error("[idempotency]", e)
+				f(w, r)
+				return
+			}
+			if !fresh {
+				idemReplay(w, r, o.Store, sk, fp)
+				return
+			}
+
+			iw := &idemWriter{ResponseWriter: w, max: o.MaxSave}
+			completed := false
+			defer func() {
+				// 请求结束后保存或释放占位，即使 handler panic 也不应一直占用 key
+				sctx := context.WithoutCancel(ctx)
+				if !completed || iw.status >= 500 || iw.overflow {
+					_ = o.Store.Delete(sctx, sk)
+					return
+				}
+				h := iw.Header().Clone()
+				for _, k := range idemSkipHeaders {
+					h.Del(k)
+				}
+				b, _ := json.Marshal(idemRecord{Fingerprint: fp, Status: iw.status, Header: h, Body: iw.buf.Bytes()})
+				if e := o.Store.Set(sctx, sk, b, o.TTL); e != nil {
+					LoggerFrom(ctx).Error("[idempotency] save:", e)
+				}
+			}()
+			f(iw, r)
+			if iw.status == 0 {
+				iw.status = http.StatusOK
+			}
+			completed = true
+		}
+	}
+}
+
+func idemReplay(w http.ResponseWriter, r *http.Request, store kv.Store, sk, fp string) {
+	var rec idemRecord
+	b, e := store.Get(r.Context(), sk)
+	if e == nil {
+		e = json.Unmarshal(b, &rec)
+	}
+	if errors.Is(e, kv.ErrNotFound) {
+		// 占位刚被释放，例如首次请求返回了 5xx
+		rec.Pending = true
+		rec.Fingerprint = fp
+	} else if e != nil {
+		LoggerFrom(r.Context()).Error("[idempotency]", e)
+		her, status := HerServiceUnavailable("idempotency store unavailable")
+		HttpReturnHER(&w, &her, status, r.URL.Path)
+		return
+	}
+	var (
+		her    HttpErrReturn
+		status StatusCode
+	)
+	switch {
+	case rec.Fingerprint != fp:
+		her, status = HerConflict("idempotency key reused with a different request")
+	case rec.Pending:
+		her, status = HerConflict("a request with the same idempotency key is in progress")
+	default:
+		h := w.Header()
+		for k, vs := range rec.Header {
+			h[k] = vs
+		}
+		h.Set(HeaderIdempotentReplayed, "true")
+		w.WriteHeader(rec.Status)
+		_, _ = w.Write(rec.Body)
+		return
+	}
+	HttpReturnHER(&w, &her, status, r.URL.Path)
+}
+
+// 方法、路径、query 与 body 的摘要，body 读取后替换为可重复读取的副本
+// body 上限与路由一致（Route.MaxBody，未设置时为 server.cfg 的 [http] max_body_size）
+func idemFingerprint(r *http.Request) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(r.Method + "\n" + r.URL.Path + "\n" + r.URL.RawQuery + "\n"))
+	if r.Body != nil && r.Body != http.NoBody {
+		var limit int64
+		if rt := routeOf(r); rt != nil {
+			limit = rt.maxBody
+		}
+		limit = bodyLimit(limit)
+		if limit > 0 && r.ContentLength > limit {
+			return "", &http.MaxBytesError{Limit: limit}
+		}
+		rd := io.Reader(r.Body)
+		if limit > 0 {
+			rd = io.LimitReader(r.Body, limit+1)
+		}
+		b, e := io.ReadAll(rd)
+		_ = r.Body.Close()
+		if e != nil {
+			return "", FieldErrors{{Field: "body", Source: BindBody, Reason: "read", Desc: e.Error()}}
+		}
+		if limit > 0 && int64(len(b)) > limit {
+			return "", &http.MaxBytesError{Limit: limit}
+		}
+		h.Write(b)
+		r.Body = io.NopCloser(bytes.NewReader(b))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func principalOf(r *http.Request) string {
+	if k := APIKeyFrom(r.Context()); k != nil {
+		return "k:" + k.ID
+	}
+	if c := ClaimsFrom(r.Context()); c != nil && c.Subject != "" {
+		return "u:" + c.Subject
+	}
+	return "ip:" + mwu.GetIP(r)
+}
+
+func (iw *idemWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
+
+func (iw *idemWriter) WriteHeader(status int) {
+	if iw.status == 0 && status >= 200 {
+		iw.status = status
+	}
+	iw.ResponseWriter.WriteHeader(status)
+}
+
+func (iw *idemWriter) Write(b []byte) (int, error) {
+	if iw.status == 0 {
+		iw.status = http.StatusOK
+	}
+	if !iw.overflow {
+		if iw.buf.Len()+len(b) > iw.max {
+			iw.overflow = true
+			iw.buf.Reset()
+		} else {
+			iw.buf.Write(b)
+		}
+	}
+	return iw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,139 @@
+package cc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+)
+
+func idemRequest(path, key, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(HeaderIdempotencyKey, key)
+	}
+	return r
+}
+
+func TestIdempotency(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		release = make(chan struct{})
+		started = make(chan struct{})
+	)
+	a := ActionGroup{Path: "/t_idem"}.Use(Idempotency())
+	a.POST("/order", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		n := calls.Add(1)
+		b, _ := io.ReadAll(ap.R.Body)
+		(*ap.W).Header().Set("X-Order", strconv.Itoa(int(n)))
+		return HerOkWithString(string(b) + ":" + strconv.Itoa(int(n)))
+	})
+	a.POST("/fail", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		calls.Add(1)
+		return HerFromError(io.ErrUnexpectedEOF)
+	})
+	a.POST("/slow", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		close(started)
+		<-release
+		return HerOk()
+	})
+
+	w1, her1 := serve(idemRequest("/t_idem/order", "k1", "a"))
+	if w1.Code != http.StatusOK || her1.Data != "a:1" || w1.Header().Get(HeaderIdempotentReplayed) != "" {
+		t.Fatalf("first: %d %+v %v", w1.Code, her1, w1.Header())
+	}
+	w2, _ := serve(idemRequest("/t_idem/order", "k1", "a"))
+	if w2.Code != http.StatusOK || w2.Body.String() != w1.Body.String() || w2.Header().Get("X-Order") != "1" ||
+		w2.Header().Get(HeaderIdempotentReplayed) != "true" || calls.Load() != 1 {
+		t.Fatalf("replay: %d %q %v calls=%d", w2.Code, w2.Body, w2.Header(), calls.Load())
+	}
+	if w2.Header().Get(HeaderRequestID) == w1.Header().Get(HeaderRequestID) {
+		t.Error("request id replayed")
+	}
+
+	// 同一 key 不同的 body
+	if w, her := serve(idemRequest("/t_idem/order", "k1", "b")); w.Code != http.StatusConflict || her.ErrCod != err_code.ERR_CONFLICT {
+		t.Errorf("conflict: %d %+v", w.Code, her)
+	}
+	// 不同的 key、没有 key 都正常执行
+	if _, her := serve(idemRequest("/t_idem/order", "k2", "a")); her.Data != "a:2" {
+		t.Errorf("other key: %+v", her)
+	}
+	if _, her := serve(idemRequest("/t_idem/order", "", "a")); her.Data != "a:3" {
+		t.Errorf("no key: %+v", her)
+	}
+	if _, her := serve(idemRequest("/t_idem/order", strings.Repeat("k", 256), "a")); her.ErrCod != err_code.ERR_INVALID_ARGUMENT {
+		t.Errorf("overlong key accepted: %+v", her)
+	}
+
+	// 5xx 不保存，可用同一 key 重试
+	before := calls.Load()
+	for i := 0; i < 2; i++ {
+		if w, _ := serve(idemRequest("/t_idem/fail", "k3", "")); w.Code != http.StatusInternalServerError {
+			t.Fatalf("fail: %d", w.Code)
+		}
+	}
+	if calls.Load()-before != 2 {
+		t.Errorf("5xx response replayed")
+	}
+
+	// 首次请求仍在处理中
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serve(idemRequest("/t_idem/slow", "k4", ""))
+	}()
+	<-started
+	if w, her := serve(idemRequest("/t_idem/slow", "k4", "")); w.Code != http.StatusConflict || !strings.Contains(her.Desc, "in progress") {
+		t.Errorf("in progress: %d %+v", w.Code, her)
+	}
+	close(release)
+	<-done
+	if w, _ := serve(idemRequest("/t_idem/slow", "k4", "")); w.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("completed request not replayed: %v", w.Header())
+	}
+}
+
+// 计算摘要时使用路由的 body 上限
+func TestIdempotencyMaxBody(t *testing.T) {
+	old := config.MaxBodySize
+	config.MaxBodySize = 16
+	defer func() { config.MaxBodySize = old }()
+
+	a := ActionGroup{Path: "/t_idem_max"}.Use(Idempotency())
+	echo := func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		b, e := io.ReadAll(ap.R.Body)
+		if e != nil {
+			return HerFromError(e)
+		}
+		return HerOkWithString(strconv.Itoa(len(b)))
+	}
+	a.POST("/big", echo).MaxBody(1024)
+	a.POST("/small", echo).MaxBody(8)
+	a.POST("/default", echo)
+
+	cases := []struct {
+		path string
+		size int
+		code int
+	}{
+		{"/t_idem_max/big", 100, http.StatusOK},
+		{"/t_idem_max/big", 2000, http.StatusRequestEntityTooLarge},
+		{"/t_idem_max/small", 8, http.StatusOK},
+		{"/t_idem_max/small", 9, http.StatusRequestEntityTooLarge},
+		{"/t_idem_max/default", 16, http.StatusOK},
+		{"/t_idem_max/default", 17, http.StatusRequestEntityTooLarge},
+	}
+	for i, c := range cases {
+		w, her := serve(idemRequest(c.path, "m"+strconv.Itoa(i), strings.Repeat("x", c.size)))
+		if w.Code != c.code || c.code == http.StatusOK && her.Data != strconv.Itoa(c.size) {
+			t.Errorf("%s %d bytes: %d %+v", c.path, c.size, w.Code, her)
+		}
+	}
+}
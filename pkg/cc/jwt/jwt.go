@@ -0,0 +1,277 @@
+// JWT 的签发与校验
+// 支持 HS256、RS256、EdDSA(Ed25519)
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+type (
+	// 标准声明，其余声明保存在 Raw 中
+	Claims struct {
+		Issuer    string   `json:"iss,omitempty"`
+		Subject   string   `json:"sub,omitempty"`
+		Audience  Audience `json:"aud,omitempty"`
+		ExpiresAt int64    `json:"exp,omitempty"`
+		NotBefore int64    `json:"nbf,omitempty"`
+		IssuedAt  int64    `json:"iat,omitempty"`
+		ID        string   `json:"jti,omitempty"`
+		// 以空格分隔的权限范围
+		Scope string `json:"scope,omitempty"`
+
+		Raw map[string]json.RawMessage `json:"-"`
+	}
+
+	// aud 可为字符串或字符串数组
+	Audience []string
+
+	header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ,omitempty"`
+		Kid string `json:"kid,omitempty"`
+	}
+
+	Options struct {
+		Keys *KeySet
+		// 允许的算法，默认 HS256、RS256、EdDSA
+		Algorithms []string
+		// 不为空时校验 iss
+		Issuer string
+		// 不为空时校验 aud 包含该值
+		Audience string
+		// 校验 exp、nbf 时允许的时钟偏差
+		Leeway time.Duration
+		// 要求必须有 exp
+		RequireExp bool
+	}
+
+	Verifier struct {
+		o Options
+	}
+)
+
+var (
+	ErrMalformed     = errors.New("jwt: malformed token")
+	ErrAlgorithm     = errors.New("jwt: algorithm not allowed")
+	ErrUnknownKey    = errors.New("jwt: no matching key")
+	ErrSignature     = errors.New("jwt: invalid signature")
+	ErrExpired       = errors.New("jwt: token expired")
+	ErrNotValidYet   = errors.New("jwt: token not valid yet")
+	ErrIssuer        = errors.New("jwt: invalid issuer")
+	ErrAudience      = errors.New("jwt: invalid audience")
+	ErrKeyType       = errors.New("jwt: key type does not match algorithm")
+	defaultAlgorithm = []string{HS256, RS256, EdDSA}
+	b64              = base64.RawURLEncoding
+)
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var ss []string
+	if e := json.Unmarshal(b, &ss); e != nil {
+		return e
+	}
+	*a = ss
+	return nil
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+func (c *Claims) HasScope(s string) bool {
+	return slices.Contains(c.Scopes(), s)
+}
+
+// 将声明 name 解析到 v 中，不存在时返回 false
+func (c *Claims) Get(name string, v interface{}) bool {
+	raw, ok := c.Raw[name]
+	return ok && json.Unmarshal(raw, v) == nil
+}
+
+func NewVerifier(o Options) *Verifier {
+	if len(o.Algorithms) == 0 {
+		o.Algorithms = defaultAlgorithm
+	}
+	return &Verifier{o: o}
+}
+
+// 校验签名与声明
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var h header
+	if e := decodeSegment(parts[0], &h); e != nil {
+		return nil, ErrMalformed
+	}
+	if !slices.Contains(v.o.Algorithms, h.Alg) {
+		return nil, ErrAlgorithm
+	}
+	sig, e := b64.DecodeString(parts[2])
+	if e != nil {
+		return nil, ErrMalformed
+	}
+	keys := v.o.Keys.lookup(h.Kid, h.Alg)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if e := verify(h.Alg, k.Key, signed, sig); e == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrSignature
+	}
+
+	c := &Claims{}
+	if e := decodeSegment(parts[1], c); e != nil {
+		return nil, ErrMalformed
+	}
+	if e := decodeSegment(parts[1], &c.Raw); e != nil {
+		return nil, ErrMalformed
+	}
+	if e := v.validate(c); e != nil {
+		return nil, e
+	}
+	return c, nil
+}
+
+func (v *Verifier) validate(c *Claims) error {
+	now := time.Now()
+	if c.ExpiresAt == 0 && v.o.RequireExp {
+		return ErrExpired
+	}
+	if c.ExpiresAt != 0 && now.After(time.Unix(c.ExpiresAt, 0).Add(v.o.Leeway)) {
+		return ErrExpired
+	}
+	if c.NotBefore != 0 && now.Add(v.o.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrNotValidYet
+	}
+	if v.o.Issuer != "" && c.Issuer != v.o.Issuer {
+		return ErrIssuer
+	}
+	if v.o.Audience != "" && !slices.Contains(c.Audience, v.o.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
+
+// 签发 token，claims 为 Claims 或任意可序列化为 json 对象的值
+// key 为 []byte(HS256)、*rsa.PrivateKey(RS256) 或 ed25519.PrivateKey(EdDSA)
+func Sign(claims interface{}, alg, kid string, key interface{}) (string, error) {
+	hj, e := json.Marshal(header{Alg: alg, Typ: "JWT", Kid: kid})
+	if e != nil {
+		return "", e
+	}
+	cj, e := json.Marshal(claims)
+	if e != nil {
+		return "", e
+	}
+	signed := b64.EncodeToString(hj) + "." + b64.EncodeToString(cj)
+	var sig []byte
+	switch alg {
+	case HS256:
+		k, ok := key.([]byte)
+		if !ok {
+			return "", ErrKeyType
+		}
+		sig = hmacSum(k, signed)
+	case RS256:
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", ErrKeyType
+		}
+		d := sha256.Sum256([]byte(signed))
+		if sig, e = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, d[:]); e != nil {
+			return "", e
+		}
+	case EdDSA:
+		k, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return "", ErrKeyType
+		}
+		sig = ed25519.Sign(k, []byte(signed))
+	default:
+		return "", ErrAlgorithm
+	}
+	return signed + "." + b64.EncodeToString(sig), nil
+}
+
+// 密钥类型必须与算法一致，防止算法混淆
+func verify(alg string, key interface{}, signed, sig []byte) error {
+	switch alg {
+	case HS256:
+		k, ok := key.([]byte)
+		if !ok {
+			return ErrKeyType
+		}
+		if !hmac.Equal(sig, hmacSum(k, string(signed))) {
+			return ErrSignature
+		}
+		return nil
+	case RS256:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrKeyType
+		}
+		d := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, d[:], sig)
+	case EdDSA:
+		k, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrKeyType
+		}
+		if !ed25519.Verify(k, signed, sig) {
+			return ErrSignature
+		}
+		return nil
+	}
+	return ErrAlgorithm
+}
+
+func hmacSum(key []byte, s string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(s))
+	return m.Sum(nil)
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, e := b64.DecodeString(s)
+	if e != nil {
+		return e
+	}
+	return json.Unmarshal(b, v)
+}
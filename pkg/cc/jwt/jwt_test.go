@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := []byte("secret")
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	epub, epriv, _ := ed25519.GenerateKey(rand.Reader)
+	ks := NewKeySet(
+		Key{ID: "h", Alg: HS256, Key: secret},
+		Key{ID: "r", Alg: RS256, Key: &rk.PublicKey},
+		Key{ID: "e", Alg: EdDSA, Key: epub},
+	)
+	v := NewVerifier(Options{Keys: ks, Issuer: "cc", Audience: "api", Leeway: time.Minute})
+	now := time.Now().Unix()
+	ok := Claims{Issuer: "cc", Audience: Audience{"api", "web"}, ExpiresAt: now + 60, Scope: "read write"}
+
+	for _, tc := range []struct {
+		alg, kid string
+		key      interface{}
+	}{{HS256, "h", secret}, {RS256, "r", rk}, {EdDSA, "e", epriv}} {
+		tok, e := Sign(ok, tc.alg, tc.kid, tc.key)
+		if e != nil {
+			t.Fatal(tc.alg, e)
+		}
+		c, e := v.Verify(tok)
+		if e != nil {
+			t.Fatal(tc.alg, e)
+		}
+		if !c.HasScope("write") || c.HasScope("admin") {
+			t.Error(tc.alg, "scopes", c.Scopes())
+		}
+	}
+
+	bad := []struct {
+		name   string
+		claims Claims
+		alg    string
+		key    interface{}
+		want   error
+	}{
+		{"expired", Claims{Issuer: "cc", Audience: Audience{"api"}, ExpiresAt: now - 120}, HS256, secret, ErrExpired},
+		{"leeway", Claims{Issuer: "cc", Audience: Audience{"api"}, ExpiresAt: now - 30}, HS256, secret, nil},
+		{"nbf", Claims{Issuer: "cc", Audience: Audience{"api"}, NotBefore: now + 120}, HS256, secret, ErrNotValidYet},
+		{"issuer", Claims{Issuer: "x", Audience: Audience{"api"}}, HS256, secret, ErrIssuer},
+		{"audience", Claims{Issuer: "cc", Audience: Audience{"web"}}, HS256, secret, ErrAudience},
+		{"signature", ok, HS256, []byte("other"), ErrSignature},
+	}
+	for _, tc := range bad {
+		tok, _ := Sign(tc.claims, tc.alg, "", tc.key)
+		if _, e := v.Verify(tok); !errors.Is(e, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, e, tc.want)
+		}
+	}
+
+	// 用 RSA 公钥作为 HMAC 密钥伪造的 token 不应通过
+	forged, _ := Sign(ok, HS256, "r", rk.PublicKey.N.Bytes())
+	if _, e := v.Verify(forged); e == nil {
+		t.Error("algorithm confusion accepted")
+	}
+	if _, e := v.Verify("eyJhbGciOiJub25lIn0.e30."); !errors.Is(e, ErrAlgorithm) {
+		t.Error("alg none:", e)
+	}
+}
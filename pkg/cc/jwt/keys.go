@@ -0,0 +1,191 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kpango/glg"
+)
+
+type (
+	// Key 为 []byte(HS256)、*rsa.PublicKey(RS256) 或 ed25519.PublicKey(EdDSA)
+	Key struct {
+		ID  string
+		Alg string
+		Key interface{}
+	}
+
+	// 校验用的密钥集合，可从 JWKS 文件加载并在文件变化时自动重新加载（密钥轮换）
+	KeySet struct {
+		mu      sync.RWMutex
+		keys    []Key
+		path    string
+		modTime time.Time
+		checked time.Time
+		refresh time.Duration
+	}
+
+	jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+		Crv string `json:"crv"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		X   string `json:"x"`
+		K   string `json:"k"`
+	}
+)
+
+var (
+	ErrJWKS = errors.New("jwt: invalid jwks")
+)
+
+func NewKeySet(keys ...Key) *KeySet {
+	return &KeySet{keys: keys}
+}
+
+// HS256 共享密钥
+func NewHMACKeySet(secret []byte) *KeySet {
+	return NewKeySet(Key{Alg: HS256, Key: secret})
+}
+
+// 从 JWKS 文件加载，之后每隔 refresh 检查文件修改时间，变化时重新加载
+// refresh 为 0 则不重新加载
+func LoadJWKS(path string, refresh time.Duration) (*KeySet, error) {
+	ks := &KeySet{path: path, refresh: refresh}
+	if e := ks.Reload(); e != nil {
+		return nil, e
+	}
+	return ks, nil
+}
+
+// 重新读取 JWKS 文件
+func (ks *KeySet) Reload() error {
+	fi, e := os.Stat(ks.path)
+	if e != nil {
+		return e
+	}
+	b, e := os.ReadFile(ks.path)
+	if e != nil {
+		return e
+	}
+	keys, e := ParseJWKS(b)
+	if e != nil {
+		return e
+	}
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.modTime = fi.ModTime()
+	ks.checked = time.Now()
+	ks.mu.Unlock()
+	return nil
+}
+
+// 解析 JWKS，不支持的密钥会被忽略
+func ParseJWKS(b []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if e := json.Unmarshal(b, &set); e != nil {
+		return nil, errors.Join(ErrJWKS, e)
+	}
+	var keys []Key
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, alg, e := k.parse()
+		if e != nil {
+			glg.Warn("[jwt] skip jwk ", k.Kid, ": ", e)
+			continue
+		}
+		if k.Alg != "" && k.Alg != alg {
+			glg.Warn("[jwt] skip jwk ", k.Kid, ": unsupported alg ", k.Alg)
+			continue
+		}
+		keys = append(keys, Key{ID: k.Kid, Alg: alg, Key: key})
+	}
+	if len(keys) == 0 {
+		return nil, ErrJWKS
+	}
+	return keys, nil
+}
+
+func (k jwk) parse() (interface{}, string, error) {
+	switch k.Kty {
+	case "oct":
+		b, e := b64.DecodeString(k.K)
+		if e != nil || len(b) == 0 {
+			return nil, "", ErrJWKS
+		}
+		return b, HS256, nil
+	case "RSA":
+		n, e1 := b64.DecodeString(k.N)
+		ex, e2 := b64.DecodeString(k.E)
+		if e1 != nil || e2 != nil || len(n) == 0 || len(ex) == 0 || len(ex) > 4 {
+			return nil, "", ErrJWKS
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(ex).Int64())}, RS256, nil
+	case "OKP":
+		x, e := b64.DecodeString(k.X)
+		if k.Crv != "Ed25519" || e != nil || len(x) != ed25519.PublicKeySize {
+			return nil, "", ErrJWKS
+		}
+		return ed25519.PublicKey(x), EdDSA, nil
+	}
+	return nil, "", ErrJWKS
+}
+
+// 按 kid 与算法查找密钥；token 没有 kid 时返回该算法的所有密钥
+func (ks *KeySet) lookup(kid, alg string) []Key {
+	if ks == nil {
+		return nil
+	}
+	ks.maybeReload()
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	var res []Key
+	for _, k := range ks.keys {
+		if k.Alg != alg {
+			continue
+		}
+		if kid != "" && k.ID != "" && k.ID != kid {
+			continue
+		}
+		res = append(res, k)
+	}
+	return res
+}
+
+func (ks *KeySet) maybeReload() {
+	if ks.path == "" || ks.refresh <= 0 {
+		return
+	}
+	ks.mu.Lock()
+	if time.Since(ks.checked) < ks.refresh {
+		ks.mu.Unlock()
+		return
+	}
+	ks.checked = time.Now()
+	modTime := ks.modTime
+	ks.mu.Unlock()
+
+	fi, e := os.Stat(ks.path)
+	if e != nil || fi.ModTime().Equal(modTime) {
+		return
+	}
+	if e := ks.Reload(); e != nil {
+		// 保留原有密钥
+		glg.Error("[jwt] reload jwks ", ks.path, ": ", e)
+		return
+	}
+	glg.Info("[jwt] jwks reloaded: ", ks.path)
+}
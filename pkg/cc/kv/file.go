@@ -0,0 +1,181 @@
+package kv
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type (
+	// 目录存储，每个键一个文件，文件名为键的 sha256
+	// 文件内容为 8 字节的过期时间（unix 纳秒，0 为永不过期）加数据
+	// 文件总是先写入临时文件再改名或链接，读取时不会看到写了一半的内容
+	// SetNX 与过期删除只在进程内互斥，多个进程共用同一目录时，过期键上的 SetNX 可能同时成功
+	File struct {
+		Dir string
+		mu  sync.Mutex
+	}
+)
+
+func NewFile(dir string) (*File, error) {
+	if e := os.MkdirAll(dir, 0700); e != nil {
+		return nil, e
+	}
+	return &File{Dir: dir}, nil
+}
+
+func (f *File) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.Dir, hex.EncodeToString(sum[:]))
+}
+
+func (f *File) Get(_ context.Context, key string) ([]byte, error) {
+	p := f.path(key)
+	h, e := os.Open(p)
+	if errors.Is(e, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if e != nil {
+		return nil, e
+	}
+	defer h.Close()
+	fi, e := h.Stat()
+	if e != nil {
+		return nil, e
+	}
+	b, e := io.ReadAll(h)
+	if e != nil {
+		return nil, e
+	}
+	if len(b) < 8 {
+		f.remove(p, fi)
+		return nil, ErrNotFound
+	}
+	if n := int64(binary.BigEndian.Uint64(b)); n != 0 && expired(time.Unix(0, n)) {
+		f.remove(p, fi)
+		return nil, ErrNotFound
+	}
+	return b[8:], nil
+}
+
+// 删除读到的文件；期间已被 Set 或 SetNX 替换时保留新的文件
+func (f *File) remove(p string, fi os.FileInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cur, e := os.Stat(p); e == nil && os.SameFile(cur, fi) {
+		_ = os.Remove(p)
+	}
+}
+
+func encodeFile(val []byte, ttl time.Duration) []byte {
+	var n int64
+	if exp := expireAt(ttl); !exp.IsZero() {
+		n = exp.UnixNano()
+	}
+	b := make([]byte, 8, 8+len(val))
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return append(b, val...)
+}
+
+// 写入临时文件，返回其路径
+func (f *File) writeTemp(val []byte, ttl time.Duration) (string, error) {
+	tmp, e := os.CreateTemp(f.Dir, ".tmp-*")
+	if e != nil {
+		return "", e
+	}
+	if _, e = tmp.Write(encodeFile(val, ttl)); e == nil {
+		e = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if e != nil {
+		_ = os.Remove(tmp.Name())
+		return "", e
+	}
+	return tmp.Name(), nil
+}
+
+func (f *File) Set(_ context.Context, key string, val []byte, ttl time.Duration) error {
+	// 先写临时文件再改名，避免读到写了一半的内容
+	tmp, e := f.writeTemp(val, ttl)
+	if e != nil {
+		return e
+	}
+	if e = os.Rename(tmp, f.path(key)); e != nil {
+		_ = os.Remove(tmp)
+	}
+	return e
+}
+
+// 写入临时文件后以硬链接放到目标位置，目标已存在时链接失败，内容与创建一步完成
+// 已过期的文件会先被删除
+func (f *File) SetNX(ctx context.Context, key string, val []byte, ttl time.Duration) (bool, error) {
+	tmp, e := f.writeTemp(val, ttl)
+	if e != nil {
+		return false, e
+	}
+	defer os.Remove(tmp)
+	p := f.path(key)
+	for range 2 {
+		f.mu.Lock()
+		e := os.Link(tmp, p)
+		f.mu.Unlock()
+		if e == nil {
+			return true, nil
+		}
+		if !errors.Is(e, fs.ErrExist) {
+			return false, e
+		}
+		_, e = f.Get(ctx, key)
+		if e == nil {
+			return false, nil
+		}
+		if !errors.Is(e, ErrNotFound) {
+			return false, e
+		}
+		// 已过期并被 Get 删除，重试一次
+	}
+	return false, nil
+}
+
+func (f *File) Delete(_ context.Context, key string) error {
+	e := os.Remove(f.path(key))
+	if errors.Is(e, fs.ErrNotExist) {
+		return nil
+	}
+	return e
+}
+
+// 删除所有过期的文件，可定期调用
+func (f *File) Sweep() error {
+	es, e := os.ReadDir(f.Dir)
+	if e != nil {
+		return e
+	}
+	for _, de := range es {
+		if de.IsDir() || len(de.Name()) != sha256.Size*2 {
+			continue
+		}
+		p := filepath.Join(f.Dir, de.Name())
+		h, e := os.Open(p)
+		if e != nil {
+			continue
+		}
+		var b [8]byte
+		_, e = io.ReadFull(h, b[:])
+		fi, se := h.Stat()
+		_ = h.Close()
+		if n := int64(binary.BigEndian.Uint64(b[:])); e == nil && se == nil && n != 0 && expired(time.Unix(0, n)) {
+			f.remove(p, fi)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// 带过期时间的键值存储，用于 session、缓存等
+package kv
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type (
+	// ttl <= 0 为永不过期
+	Store interface {
+		// 不存在或已过期时返回 ErrNotFound
+		Get(ctx context.Context, key string) ([]byte, error)
+		Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+		Delete(ctx context.Context, key string) error
+		// 键不存在（或已过期）时设置并返回 true，否则返回 false
+		SetNX(ctx context.Context, key string, val []byte, ttl time.Duration) (bool, error)
+	}
+
+	// 进程内存储，重启后丢失
+	Memory struct {
+		mu    sync.Mutex
+		m     map[string]memItem
+		swept time.Time
+	}
+
+	memItem struct {
+		val []byte
+		exp time.Time
+	}
+)
+
+var (
+	ErrNotFound = errors.New("kv: not found")
+)
+
+func NewMemory() *Memory {
+	return &Memory{m: map[string]memItem{}, swept: time.Now()}
+}
+
+func expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func expired(exp time.Time) bool {
+	return !exp.IsZero() && time.Now().After(exp)
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.m[key]
+	if !ok || expired(it.exp) {
+		delete(m.m, key)
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), it.val...), nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, val []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m[key] = memItem{val: append([]byte(nil), val...), exp: expireAt(ttl)}
+	m.sweep()
+	return nil
+}
+
+func (m *Memory) SetNX(_ context.Context, key string, val []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if it, ok := m.m[key]; ok && !expired(it.exp) {
+		return false, nil
+	}
+	m.m[key] = memItem{val: append([]byte(nil), val...), exp: expireAt(ttl)}
+	m.sweep()
+	return true, nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.m, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// 每分钟最多清理一次过期的键
+func (m *Memory) sweep() {
+	if time.Since(m.swept) < time.Minute {
+		return
+	}
+	m.swept = time.Now()
+	for k, it := range m.m {
+		if expired(it.exp) {
+			delete(m.m, k)
+		}
+	}
+}
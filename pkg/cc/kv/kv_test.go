@@ -0,0 +1,121 @@
+package kv
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	if _, e := s.Get(ctx, "a"); e != ErrNotFound {
+		t.Fatalf("empty get: %v", e)
+	}
+	if e := s.Set(ctx, "a", []byte("1"), 0); e != nil {
+		t.Fatal(e)
+	}
+	if e := s.Set(ctx, "b", []byte("2"), 30*time.Millisecond); e != nil {
+		t.Fatal(e)
+	}
+	if b, e := s.Get(ctx, "b"); e != nil || string(b) != "2" {
+		t.Fatalf("get b: %q %v", b, e)
+	}
+	if ok, e := s.SetNX(ctx, "b", []byte("3"), 0); ok || e != nil {
+		t.Fatalf("SetNX on live key: %v %v", ok, e)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, e := s.Get(ctx, "b"); e != ErrNotFound {
+		t.Errorf("expired key: %v", e)
+	}
+	if b, e := s.Get(ctx, "a"); e != nil || string(b) != "1" {
+		t.Errorf("key without ttl: %q %v", b, e)
+	}
+	if ok, e := s.SetNX(ctx, "b", []byte("3"), 0); !ok || e != nil {
+		t.Errorf("SetNX on expired key: %v %v", ok, e)
+	}
+	if b, _ := s.Get(ctx, "b"); string(b) != "3" {
+		t.Errorf("after SetNX: %q", b)
+	}
+
+	if e := s.Delete(ctx, "a"); e != nil {
+		t.Fatal(e)
+	}
+	if e := s.Delete(ctx, "a"); e != nil {
+		t.Errorf("delete missing key: %v", e)
+	}
+	if _, e := s.Get(ctx, "a"); e != ErrNotFound {
+		t.Errorf("deleted key: %v", e)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestFile(t *testing.T) {
+	f, e := NewFile(t.TempDir())
+	if e != nil {
+		t.Fatal(e)
+	}
+	testStore(t, f)
+
+	ctx := context.Background()
+	_ = f.Set(ctx, "x", []byte("x"), 10*time.Millisecond)
+	_ = f.Set(ctx, "y", []byte("y"), 0)
+	time.Sleep(20 * time.Millisecond)
+	if e := f.Sweep(); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := f.Get(ctx, "y"); e != nil {
+		t.Errorf("sweep removed live key: %v", e)
+	}
+	// 直接检查文件，Get 也会删除过期的键
+	if _, e := os.Stat(f.path("x")); !os.IsNotExist(e) {
+		t.Errorf("expired file not swept: %v", e)
+	}
+}
+
+// 并发的 SetNX 只有一个成功，同时进行的 Get 不会删除刚写入的键
+func TestFileSetNXConcurrent(t *testing.T) {
+	f, _ := NewFile(t.TempDir())
+	ctx := context.Background()
+	for round := 0; round < 20; round++ {
+		key := "k" + strconv.Itoa(round)
+		if round%2 == 1 {
+			// 已过期的键
+			_ = f.Set(ctx, key, []byte("old"), time.Nanosecond)
+			time.Sleep(time.Millisecond)
+		}
+		var (
+			wg  sync.WaitGroup
+			won atomic.Int32
+		)
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if ok, e := f.SetNX(ctx, key, []byte("v"), time.Minute); e != nil {
+					t.Error(e)
+				} else if ok {
+					won.Add(1)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				_, _ = f.Get(ctx, key)
+			}()
+		}
+		wg.Wait()
+		if won.Load() != 1 {
+			t.Fatalf("round %d: %d SetNX succeeded", round, won.Load())
+		}
+		if b, e := f.Get(ctx, key); e != nil || string(b) != "v" {
+			t.Fatalf("round %d: %q %v", round, b, e)
+		}
+	}
+}
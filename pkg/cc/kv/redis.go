@@ -0,0 +1,244 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+)
+
+type (
+	// 使用 RESP 协议的存储，兼容 Redis 及其协议兼容的实现
+	Redis struct {
+		addr     string
+		password string
+		db       int
+		idle     chan *redisConn
+		active   chan struct{}
+		// 单个命令的超时，ctx 没有 deadline 时使用，默认 5s
+		Timeout time.Duration
+	}
+
+	redisConn struct {
+		c  net.Conn
+		br *bufio.Reader
+	}
+
+	// 服务端返回的错误
+	RedisError string
+)
+
+var (
+	ErrRedisPoolExhausted = errors.New("kv: redis connection pool exhausted")
+)
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// 按 server.cfg 的 [redis] 段创建
+// MaxIdle 为空闲连接上限，MaxActive 为连接总数上限（0 不限制）
+func NewRedis(cfg config.RedisConfig) *Redis {
+	r := &Redis{
+		addr:     cfg.Addr,
+		password: cfg.Password,
+		db:       cfg.DB,
+		idle:     make(chan *redisConn, max(cfg.MaxIdle, 1)),
+		Timeout:  5 * time.Second,
+	}
+	if cfg.MaxActive > 0 {
+		r.active = make(chan struct{}, cfg.MaxActive)
+	}
+	return r
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	v, e := r.Do(ctx, "GET", key)
+	if e != nil {
+		return nil, e
+	}
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("kv: unexpected redis reply %T", v)
+	}
+	return b, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(val)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, e := r.Do(ctx, args...)
+	return e
+}
+
+func (r *Redis) SetNX(ctx context.Context, key string, val []byte, ttl time.Duration) (bool, error) {
+	args := []string{"SET", key, string(val), "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	v, e := r.Do(ctx, args...)
+	return v != nil, e
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	_, e := r.Do(ctx, "DEL", key)
+	return e
+}
+
+// 执行一条命令
+// 返回值为 string（状态）、[]byte（bulk）、int64、[]interface{} 或 nil
+func (r *Redis) Do(ctx context.Context, args ...string) (interface{}, error) {
+	if r.active != nil {
+		select {
+		case r.active <- struct{}{}:
+			defer func() { <-r.active }()
+		case <-ctx.Done():
+			return nil, errors.Join(ErrRedisPoolExhausted, ctx.Err())
+		}
+	}
+	rc, e := r.conn(ctx)
+	if e != nil {
+		return nil, e
+	}
+	dl, ok := ctx.Deadline()
+	if !ok {
+		dl = time.Now().Add(r.Timeout)
+	}
+	_ = rc.c.SetDeadline(dl)
+	stop := context.AfterFunc(ctx, func() {
+		_ = rc.c.SetDeadline(time.Now())
+	})
+	v, e := rc.do(args)
+	if !stop() || e != nil {
+		var re RedisError
+		if e != nil && errors.As(e, &re) {
+			// 命令错误，连接仍可用
+			r.put(rc)
+			return nil, e
+		}
+		_ = rc.c.Close()
+		if e == nil {
+			e = ctx.Err()
+		}
+		return nil, e
+	}
+	r.put(rc)
+	return v, nil
+}
+
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case rc := <-r.idle:
+		return rc, nil
+	default:
+	}
+	d := net.Dialer{Timeout: r.Timeout}
+	c, e := d.DialContext(ctx, "tcp", r.addr)
+	if e != nil {
+		return nil, e
+	}
+	rc := &redisConn{c: c, br: bufio.NewReader(c)}
+	_ = c.SetDeadline(time.Now().Add(r.Timeout))
+	if r.password != "" {
+		if _, e = rc.do([]string{"AUTH", r.password}); e != nil {
+			_ = c.Close()
+			return nil, e
+		}
+	}
+	if r.db != 0 {
+		if _, e = rc.do([]string{"SELECT", strconv.Itoa(r.db)}); e != nil {
+			_ = c.Close()
+			return nil, e
+		}
+	}
+	return rc, nil
+}
+
+func (r *Redis) put(rc *redisConn) {
+	select {
+	case r.idle <- rc:
+	default:
+		_ = rc.c.Close()
+	}
+}
+
+// 关闭所有空闲连接
+func (r *Redis) Close() error {
+	for {
+		select {
+		case rc := <-r.idle:
+			_ = rc.c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (rc *redisConn) do(args []string) (interface{}, error) {
+	bw := bufio.NewWriter(rc.c)
+	fmt.Fprintf(bw, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(bw, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if e := bw.Flush(); e != nil {
+		return nil, e
+	}
+	return rc.read()
+}
+
+func (rc *redisConn) read() (interface{}, error) {
+	line, e := rc.br.ReadString('\n')
+	if e != nil {
+		return nil, e
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("kv: malformed redis reply")
+	}
+	body := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, e := strconv.Atoi(body)
+		if e != nil || n < 0 {
+			return nil, e
+		}
+		b := make([]byte, n+2)
+		if _, e = io.ReadFull(rc.br, b); e != nil {
+			return nil, e
+		}
+		return b[:n], nil
+	case '*':
+		n, e := strconv.Atoi(body)
+		if e != nil || n < 0 {
+			return nil, e
+		}
+		vs := make([]interface{}, n)
+		for i := range vs {
+			if vs[i], e = rc.read(); e != nil {
+				var re RedisError
+				if !errors.As(e, &re) {
+					return nil, e
+				}
+				vs[i] = re
+			}
+		}
+		return vs, nil
+	}
+	return nil, errors.New("kv: unknown redis reply type " + string(line[0]))
+}
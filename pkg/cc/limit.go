@@ -0,0 +1,84 @@
+package cc
+
+/**
+并发限制与过载保护
+
+	global := limiter.New(limiter.Options{Limit: 200, Algorithm: &limiter.Gradient{}})
+	mw.Register(cc.ConcurrencyLimit(global))                  // 全局
+
+	a.POST("/search", search).Concurrency(limiter.New(limiter.Options{
+		Limit: 10, Queue: 20, QueueTimeout: 500 * time.Millisecond,
+		Algorithm: &limiter.AIMD{Threshold: 200 * time.Millisecond},
+	}))                                                       // 单个路由
+
+超出上限的请求排队等待，队列已满或等待超时返回 503 HER（ERR_UNAVAILABLE）与 Retry-After，
+并计入 mwu.RouteStats 的 Shed
+*/
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/limiter"
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+// 并发限制中间件，可全局注册或通过 ActionGroup.Use 用于路由组
+func ConcurrencyLimit(l *limiter.Limiter) middleware.MiddewareFunc {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			tok, e := acquire(w, r, l, routePattern(r))
+			if e != nil {
+				her, status := HerFromError(e)
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+				return
+			}
+			defer tok.Release(false)
+			f(w, r)
+		}
+	}
+}
+
+// 限制该路由的并发数，与全局的限制叠加
+func (rt *Route) Concurrency(l *limiter.Limiter) *Route {
+	rt.limiter = l
+	return rt
+}
+
+// 被拒绝时设置 Retry-After 并返回 503 的 HerError
+func acquire(w http.ResponseWriter, r *http.Request, l *limiter.Limiter, path string) (*limiter.Token, error) {
+	tok, e := l.Acquire(r.Context())
+	if e == nil {
+		return tok, nil
+	}
+	if !errors.Is(e, limiter.ErrLimited) {
+		// 客户端断开或超时
+		return nil, e
+	}
+	mwu.RecordShed(path)
+	LoggerFrom(r.Context()).Warn("[limiter] shed", path, "limit:", l.Limit())
+	w.Header().Set("Retry-After", strconv.Itoa(int((l.RetryAfter()+time.Second-1)/time.Second)))
+	return nil, NewHerError(http.StatusServiceUnavailable, err_code.ERR_UNAVAILABLE, "server overloaded")
+}
+
+// 在路由的 handler 外获取许可，返回 503（例如超时）时视为过载
+func limitRun(l *limiter.Limiter, path string, run func(http.ResponseWriter, *http.Request) (HttpErrReturn, StatusCode, bool)) func(http.ResponseWriter, *http.Request) (HttpErrReturn, StatusCode, bool) {
+	return func(w http.ResponseWriter, r *http.Request) (her HttpErrReturn, status StatusCode, ok bool) {
+		tok, e := acquire(w, r, l, path)
+		if e != nil {
+			her, status = HerFromError(e)
+			return her, status, true
+		}
+		dropped := true
+		defer func() {
+			tok.Release(dropped)
+		}()
+		her, status, ok = run(w, r)
+		dropped = status == http.StatusServiceUnavailable
+		return her, status, ok
+	}
+}
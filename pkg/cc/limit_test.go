@@ -0,0 +1,60 @@
+package cc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/limiter"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+// 上限为 1 且不排队时，第二个并发请求返回 503 与 Retry-After
+func TestConcurrencyShed(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 2)
+	h := func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		started <- struct{}{}
+		<-block
+		return HerOk()
+	}
+	opts := limiter.Options{Limit: 1, Queue: -1, RetryAfter: 2 * time.Second}
+	ActionGroup{Path: "/t_limit_mw"}.Use(ConcurrencyLimit(limiter.New(opts))).GET("/item/", h)
+	ActionGroup{Path: "/t_limit_rt"}.GET("/item/", h).Concurrency(limiter.New(opts))
+
+	for _, base := range []string{"/t_limit_mw/item/", "/t_limit_rt/item/"} {
+		first := make(chan int, 1)
+		go func() {
+			w, _ := serve(httptest.NewRequest(http.MethodGet, base+"a", nil))
+			first <- w.Code
+		}()
+		<-started
+		w, her := serve(httptest.NewRequest(http.MethodGet, base+"b", nil))
+		if w.Code != http.StatusServiceUnavailable || her.ErrCod != err_code.ERR_UNAVAILABLE || w.Header().Get("Retry-After") != "2" {
+			t.Errorf("%s: %d %+v %v", base, w.Code, her, w.Header())
+		}
+		block <- struct{}{}
+		if c := <-first; c != http.StatusOK {
+			t.Errorf("%s: first request %d", base, c)
+		}
+		// 许可已释放
+		go func() { block <- struct{}{} }()
+		if w, _ := serve(httptest.NewRequest(http.MethodGet, base+"c", nil)); w.Code != http.StatusOK {
+			t.Errorf("%s: after release %d", base, w.Code)
+		}
+		<-started
+
+		// 拒绝次数按注册的路由记录
+		var shed uint64
+		for _, s := range mwu.RouteStats() {
+			if s.Path == base {
+				shed = s.Shed
+			}
+		}
+		if shed != 1 {
+			t.Errorf("%s: shed %d, stats %+v", base, shed, mwu.RouteStats())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+type (
+	// 加性增、乘性减
+	// 请求成功且耗时不超过 Threshold 时上限加 1，失败或超过 Threshold 时乘以 Backoff
+	AIMD struct {
+		// 视为过载的耗时，0 为只按失败判断
+		Threshold time.Duration
+		// 默认 0.9
+		Backoff float64
+	}
+
+	// 按耗时的梯度调整，思路来自 Netflix concurrency-limits 的 Gradient2
+	// 比较短期与长期的平均耗时：耗时上升时按比例降低上限，平稳时缓慢增加
+	Gradient struct {
+		// 短期耗时允许为长期的倍数，默认 2
+		Tolerance float64
+		// 平滑系数，默认 0.2
+		Smoothing float64
+
+		short, long float64
+	}
+)
+
+func (a *AIMD) Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	backoff := a.Backoff
+	if backoff <= 0 || backoff >= 1 {
+		backoff = 0.9
+	}
+	if dropped || (a.Threshold > 0 && rtt > a.Threshold) {
+		return limit * backoff
+	}
+	// 实际并发远低于上限时不再增加
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+func (g *Gradient) Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	tol, sm := g.Tolerance, g.Smoothing
+	if tol < 1 {
+		tol = 2
+	}
+	if sm <= 0 || sm > 1 {
+		sm = 0.2
+	}
+	if dropped {
+		return limit * (1 - sm/2)
+	}
+	// 计时精度不足时 rtt 可能为 0，作为除数会得到 NaN
+	if rtt <= 0 {
+		return limit
+	}
+	x := float64(rtt)
+	if g.long == 0 {
+		g.short, g.long = x, x
+	}
+	g.short += (x - g.short) * 0.1
+	g.long += (x - g.long) * 0.01
+	// 长期耗时远高于短期时（例如负载下降后）让其尽快回落
+	if g.long/g.short > 2 {
+		g.long *= 0.95
+	}
+	if float64(inflight)*2 < limit {
+		return limit
+	}
+	gradient := math.Max(0.5, math.Min(1, tol*g.long/g.short))
+	n := limit*gradient + math.Sqrt(limit)
+	return limit*(1-sm) + n*sm
+}
@@ -0,0 +1,179 @@
+// 并发限制与过载保护
+//
+// Limiter 限制同时处理的请求数，超出的请求进入有界的等待队列，队列已满或等待超时时拒绝。
+// 设置 Algorithm 后按请求耗时自适应调整上限
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+type (
+	Options struct {
+		// 初始并发上限，默认 100
+		Limit int
+		// 自适应时的上下界，默认 1 与 Limit 的 10 倍
+		MinLimit int
+		MaxLimit int
+		// 等待队列长度，默认与 Limit 相同；< 0 为不排队
+		Queue int
+		// 排队等待的最长时间，默认 1 秒
+		QueueTimeout time.Duration
+		// 拒绝时建议客户端的重试间隔，默认 1 秒
+		RetryAfter time.Duration
+		// 自适应算法，nil 为固定上限
+		Algorithm Algorithm
+	}
+
+	// 根据一次请求的耗时计算新的上限
+	// 在 Limiter 的锁内调用，实现无需自行加锁
+	Algorithm interface {
+		Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64
+	}
+
+	Limiter struct {
+		o        Options
+		mu       sync.Mutex
+		limit    float64
+		inflight int
+		waiters  []*waiter
+	}
+
+	waiter struct {
+		ch      chan struct{}
+		granted bool
+	}
+
+	// 取得的许可，请求结束时调用 Release
+	Token struct {
+		l     *Limiter
+		start time.Time
+		once  sync.Once
+	}
+)
+
+var (
+	ErrLimited = errors.New("limiter: too many concurrent requests")
+)
+
+func New(o Options) *Limiter {
+	if o.Limit <= 0 {
+		o.Limit = 100
+	}
+	if o.MinLimit <= 0 {
+		o.MinLimit = 1
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = o.Limit * 10
+	}
+	if o.Queue == 0 {
+		o.Queue = o.Limit
+	}
+	if o.QueueTimeout <= 0 {
+		o.QueueTimeout = time.Second
+	}
+	if o.RetryAfter <= 0 {
+		o.RetryAfter = time.Second
+	}
+	return &Limiter{o: o, limit: float64(o.Limit)}
+}
+
+// 当前的并发上限
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cap()
+}
+
+// 正在处理的请求数
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+func (l *Limiter) RetryAfter() time.Duration {
+	return l.o.RetryAfter
+}
+
+func (l *Limiter) cap() int {
+	return int(math.Ceil(l.limit))
+}
+
+// 取得许可，必要时排队等待
+// 队列已满或等待超时返回 ErrLimited，ctx 结束时返回 ctx.Err()
+func (l *Limiter) Acquire(ctx context.Context) (*Token, error) {
+	l.mu.Lock()
+	if l.inflight < l.cap() {
+		l.inflight++
+		l.mu.Unlock()
+		return l.token(), nil
+	}
+	if l.o.Queue < 0 || len(l.waiters) >= l.o.Queue {
+		l.mu.Unlock()
+		return nil, ErrLimited
+	}
+	w := &waiter{ch: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	l.mu.Unlock()
+
+	t := time.NewTimer(l.o.QueueTimeout)
+	defer t.Stop()
+	var e error
+	select {
+	case <-w.ch:
+		return l.token(), nil
+	case <-t.C:
+		e = ErrLimited
+	case <-ctx.Done():
+		e = ctx.Err()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// 超时的同时被唤醒，许可已计入 inflight
+		return l.token(), nil
+	}
+	for i, x := range l.waiters {
+		if x == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			break
+		}
+	}
+	return nil, e
+}
+
+func (l *Limiter) token() *Token {
+	return &Token{l: l, start: time.Now()}
+}
+
+// 归还许可，dropped 表示请求因过载失败（例如超时），自适应算法会据此降低上限
+func (t *Token) Release(dropped bool) {
+	t.once.Do(func() {
+		t.l.release(time.Since(t.start), dropped)
+	})
+}
+
+func (l *Limiter) release(rtt time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.o.Algorithm != nil {
+		// NaN 会使上限永久失效，忽略
+		if n := l.o.Algorithm.Update(l.limit, rtt, l.inflight, dropped); !math.IsNaN(n) {
+			l.limit = math.Max(float64(l.o.MinLimit), math.Min(float64(l.o.MaxLimit), n))
+		}
+	}
+	l.inflight--
+	// 按先后顺序唤醒等待者
+	for len(l.waiters) > 0 && l.inflight < l.cap() {
+		w := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		w.granted = true
+		l.inflight++
+		close(w.ch)
+	}
+}
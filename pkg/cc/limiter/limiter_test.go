@@ -0,0 +1,107 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAcquireQueue(t *testing.T) {
+	l := New(Options{Limit: 1, Queue: 1, QueueTimeout: 50 * time.Millisecond})
+	ctx := context.Background()
+	t1, e := l.Acquire(ctx)
+	if e != nil {
+		t.Fatal(e)
+	}
+	got := make(chan error, 1)
+	go func() {
+		t2, e := l.Acquire(ctx)
+		if e == nil {
+			t2.Release(false)
+		}
+		got <- e
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// 队列已满
+	if _, e := l.Acquire(ctx); !errors.Is(e, ErrLimited) {
+		t.Fatalf("want ErrLimited, got %v", e)
+	}
+	t1.Release(false)
+	if e := <-got; e != nil {
+		t.Fatalf("queued acquire: %v", e)
+	}
+	if n := l.InFlight(); n != 0 {
+		t.Fatalf("inflight = %d", n)
+	}
+}
+
+func TestAcquireQueueTimeout(t *testing.T) {
+	l := New(Options{Limit: 1, QueueTimeout: 20 * time.Millisecond})
+	tok, _ := l.Acquire(context.Background())
+	defer tok.Release(false)
+	if _, e := l.Acquire(context.Background()); !errors.Is(e, ErrLimited) {
+		t.Fatalf("want ErrLimited, got %v", e)
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	a := &AIMD{Threshold: 100 * time.Millisecond}
+	if n := a.Update(10, 10*time.Millisecond, 10, false); n != 11 {
+		t.Errorf("increase: %v", n)
+	}
+	if n := a.Update(10, 200*time.Millisecond, 10, false); n != 9 {
+		t.Errorf("slow: %v", n)
+	}
+	if n := a.Update(10, 10*time.Millisecond, 1, false); n != 10 {
+		t.Errorf("app limited: %v", n)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	g := &Gradient{}
+	// rtt 为 0 的样本被忽略
+	if n := g.Update(10, 0, 10, false); n != 10 || g.short != 0 {
+		t.Fatalf("zero rtt: %v %v", n, g.short)
+	}
+	limit := 10.0
+	for i := 0; i < 50; i++ {
+		limit = g.Update(limit, 10*time.Millisecond, int(limit), false)
+	}
+	if limit <= 10 {
+		t.Fatalf("steady rtt should increase the limit: %v", limit)
+	}
+	steady := limit
+	for i := 0; i < 50; i++ {
+		limit = g.Update(limit, 200*time.Millisecond, int(limit), false)
+	}
+	if limit >= steady {
+		t.Fatalf("rising rtt should decrease the limit: %v >= %v", limit, steady)
+	}
+	if n := g.Update(limit, 0, int(limit), true); n >= limit {
+		t.Fatalf("dropped: %v", n)
+	}
+	// 实际并发远低于上限时不增加
+	if n := g.Update(100, 10*time.Millisecond, 1, false); n != 100 {
+		t.Fatalf("app limited: %v", n)
+	}
+}
+
+type nanAlgorithm struct{}
+
+func (nanAlgorithm) Update(float64, time.Duration, int, bool) float64 { return math.NaN() }
+
+func TestLimiterIgnoresNaN(t *testing.T) {
+	l := New(Options{Limit: 2, Algorithm: nanAlgorithm{}})
+	tok, _ := l.Acquire(context.Background())
+	tok.Release(false)
+	if l.Limit() != 2 {
+		t.Fatalf("limit = %d", l.Limit())
+	}
+	for i := 0; i < 2; i++ {
+		if _, e := l.Acquire(context.Background()); e != nil {
+			t.Fatal(i, e)
+		}
+	}
+}
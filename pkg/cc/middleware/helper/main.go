@@ -7,14 +7,23 @@ import (
 	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
 )
 
-func WrapPost(handler http.HandlerFunc) http.HandlerFunc {
-	return mw.HandlerWrapFully(handler, mwu.Method(mwu.POST))
+// mws 为路由组的中间件，包裹在 Method 检查之外
+func WrapPost(handler http.HandlerFunc, mws ...mw.MiddewareFunc) http.HandlerFunc {
+	return mw.HandlerWrapFully(handler, append([]mw.MiddewareFunc{mwu.Method(mwu.POST)}, mws...)...)
 }
 
-func WrapGet(handler http.HandlerFunc) http.HandlerFunc {
-	return mw.HandlerWrapFully(handler, mwu.Method(mwu.GET))
+func WrapGet(handler http.HandlerFunc, mws ...mw.MiddewareFunc) http.HandlerFunc {
+	return mw.HandlerWrapFully(handler, append([]mw.MiddewareFunc{mwu.Method(mwu.GET)}, mws...)...)
 }
 
-func WrapWS(handler http.HandlerFunc) http.HandlerFunc {
-	return mw.HandlerWrapFully(handler, mwu.Method(mwu.WS))
+func WrapPut(handler http.HandlerFunc, mws ...mw.MiddewareFunc) http.HandlerFunc {
+	return mw.HandlerWrapFully(handler, append([]mw.MiddewareFunc{mwu.Method(mwu.PUT)}, mws...)...)
+}
+
+func WrapPatch(handler http.HandlerFunc, mws ...mw.MiddewareFunc) http.HandlerFunc {
+	return mw.HandlerWrapFully(handler, append([]mw.MiddewareFunc{mwu.Method(mwu.PATCH)}, mws...)...)
+}
+
+func WrapWS(handler http.HandlerFunc, mws ...mw.MiddewareFunc) http.HandlerFunc {
+	return mw.HandlerWrapFully(handler, append([]mw.MiddewareFunc{mwu.Method(mwu.WS)}, mws...)...)
 }
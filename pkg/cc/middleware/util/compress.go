@@ -0,0 +1,278 @@
+// 响应压缩中间件
+package middlewareUtil
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+type (
+	CompressOptions struct {
+		// 服务端偏好顺序，客户端 q 值相同时靠前者优先
+		// 默认 br, zstd, gzip, deflate
+		Encodings []string
+		// 小于该字节数的响应不压缩，默认 1024
+		MinSize int
+		// 不压缩的 Content-Type 前缀，默认 DefaultCompressSkipTypes
+		SkipTypes []string
+	}
+
+	compressor interface {
+		io.WriteCloser
+		Flush() error
+		Reset(io.Writer)
+	}
+
+	compressWriter struct {
+		http.ResponseWriter
+		opts     *CompressOptions
+		encoding string
+		pool     *sync.Pool
+		c        compressor
+		buf      bytes.Buffer
+		status   int
+		decided  bool
+		compress bool
+	}
+)
+
+var (
+	// 已压缩或压缩收益很低的类型，例如 ContentType 表中的音频
+	DefaultCompressSkipTypes = []string{
+		"audio/", "video/", "image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+		"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+		"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+		"application/octet-stream", "font/woff", "font/woff2", "text/event-stream",
+	}
+
+	compressorPools = map[string]*sync.Pool{
+		EncodingGzip: {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+			return w
+		}},
+		EncodingDeflate: {New: func() interface{} {
+			w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+			return w
+		}},
+		EncodingBrotli: {New: func() interface{} {
+			return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+		}},
+		EncodingZstd: {New: func() interface{} {
+			w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+			return w
+		}},
+	}
+)
+
+// 按 Accept-Encoding 压缩响应
+//
+// 可全局注册，也可通过 ActionGroup.Use 按组启用
+// 跳过过小的响应、已压缩的类型与 websocket 升级请求，支持 Flush（SSE、流式响应）
+func Compress(opts ...CompressOptions) middleware.MiddewareFunc {
+	var o CompressOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if len(o.Encodings) == 0 {
+		o.Encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+	}
+	if o.MinSize <= 0 {
+		o.MinSize = 1024
+	}
+	if o.SkipTypes == nil {
+		o.SkipTypes = DefaultCompressSkipTypes
+	}
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			enc := negotiateEncoding(r.Header.Get("Accept-Encoding"), o.Encodings)
+			if enc == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				f(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, opts: &o, encoding: enc, pool: compressorPools[enc]}
+			defer cw.Close()
+			f(cw, r)
+		}
+	}
+}
+
+// 按 q 值与服务端偏好选择编码，无可用编码时返回空
+func negotiateEncoding(accept string, prefer []string) string {
+	if accept == "" {
+		return ""
+	}
+	qs := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, e := strconv.ParseFloat(v, 64); e == nil {
+				q = f
+			}
+		}
+		qs[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range prefer {
+		q, ok := qs[enc]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if status < 200 {
+		// 1xx 直接转发
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if cw.status != 0 {
+		return
+	}
+	cw.status = status
+	// 无 body 或部分内容的响应不压缩
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		if !cw.compressible() {
+			cw.decide(false)
+		} else {
+			cw.buf.Write(b)
+			if cw.buf.Len() >= cw.opts.MinSize {
+				if e := cw.decide(true); e != nil {
+					return 0, e
+				}
+			}
+			return len(b), nil
+		}
+	}
+	if cw.compress {
+		return cw.c.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		return true
+	}
+	mt, _, e := mime.ParseMediaType(ct)
+	if e != nil {
+		mt = ct
+	}
+	for _, s := range cw.opts.SkipTypes {
+		if strings.HasPrefix(mt, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// 决定是否压缩并写出响应头与已缓冲的数据
+func (cw *compressWriter) decide(compress bool) error {
+	if cw.decided {
+		return nil
+	}
+	cw.decided = true
+	cw.compress = compress
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if compress {
+		h := cw.Header()
+		if h.Get("Content-Type") == "" {
+			// 避免 net/http 对压缩后的数据进行类型探测
+			h.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+		}
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if et := h.Get("ETag"); et != "" && !strings.HasPrefix(et, "W/") {
+			// 压缩后内容不同，强 ETag 降级为弱 ETag
+			h.Set("ETag", "W/"+et)
+		}
+		cw.c = cw.pool.Get().(compressor)
+		cw.c.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var e error
+	if compress {
+		_, e = cw.c.Write(cw.buf.Bytes())
+	} else {
+		_, e = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return e
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		// 流式响应在首次刷新时即决定是否压缩
+		_ = cw.decide(cw.buf.Len() > 0 && cw.compressible())
+	}
+	if cw.compress {
+		_ = cw.c.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		if cw.status == 0 && cw.buf.Len() == 0 {
+			// handler 没有写出任何内容
+			return
+		}
+		// 不足 MinSize，直接写出
+		_ = cw.decide(false)
+	}
+	if cw.compress {
+		_ = cw.c.Close()
+		cw.c.Reset(io.Discard)
+		cw.pool.Put(cw.c)
+		cw.c = nil
+		cw.compress = false
+	}
+}
@@ -0,0 +1,107 @@
+package middlewareUtil
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	prefer := []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+	cases := map[string]string{
+		"":                        "",
+		"gzip, deflate":           EncodingGzip,
+		"gzip, deflate, br":       EncodingBrotli,
+		"br;q=0.5, gzip":          EncodingGzip,
+		"GZIP;q=0.8, zstd;q=0.8":  EncodingZstd,
+		"*":                       EncodingBrotli,
+		"*;q=0.1, gzip;q=0.2":     EncodingGzip,
+		"br;q=0, *;q=0.5":         EncodingZstd,
+		"identity":                "",
+		"gzip;q=0":                "",
+		"gzip; q=0.3 , deflate ;": EncodingDeflate,
+	}
+	for accept, want := range cases {
+		if got := negotiateEncoding(accept, prefer); got != want {
+			t.Errorf("%q: got %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	big := strings.Repeat("hello ccgo ", 200)
+	h := Compress()(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = io.WriteString(w, big[:len(big)/2])
+			_, _ = io.WriteString(w, big[len(big)/2:])
+		case "/small":
+			_, _ = io.WriteString(w, "tiny")
+		case "/audio":
+			w.Header().Set("Content-Type", "audio/x-flac")
+			_, _ = io.WriteString(w, big)
+		case "/304":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	})
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept-Encoding", accept)
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	w := do("/big", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") != `W/"v1"` || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatal(w.Header())
+	}
+	zr, e := gzip.NewReader(w.Body)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != big {
+		t.Fatal("gzip body mismatch")
+	}
+
+	w = do("/big", "br")
+	if b, _ := io.ReadAll(brotli.NewReader(w.Body)); w.Header().Get("Content-Encoding") != "br" || string(b) != big {
+		t.Fatal(w.Header())
+	}
+
+	for path, body := range map[string]string{"/small": "tiny", "/audio": big, "/304": ""} {
+		w = do(path, "gzip, br")
+		if w.Header().Get("Content-Encoding") != "" || w.Body.String() != body {
+			t.Fatal(path, w.Header(), w.Body.Len())
+		}
+	}
+	if w = do("/big", ""); w.Header().Get("Content-Encoding") != "" || w.Body.String() != big {
+		t.Fatal(w.Header())
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	h := Compress()(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, "{\"n\":1}\n")
+		http.NewResponseController(w).Flush()
+		_, _ = io.WriteString(w, "{\"n\":2}\n")
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h(w, r)
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal(w.Flushed, w.Header())
+	}
+	zr, _ := gzip.NewReader(w.Body)
+	if b, _ := io.ReadAll(zr); string(b) != "{\"n\":1}\n{\"n\":2}\n" {
+		t.Fatalf("%q", b)
+	}
+}
@@ -0,0 +1,199 @@
+// 跨域资源共享中间件
+package middlewareUtil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+
+	"github.com/kpango/glg"
+)
+
+type (
+	CORSOptions struct {
+		// 允许的源，支持 * 与子域名通配，如 https://*.example.com
+		AllowOrigins []string
+		// 以正则匹配允许的源，须匹配整个源（自动加上 ^ 与 $）
+		AllowOriginPatterns []string
+		// 自定义判断，优先于以上两者
+		AllowOriginFunc func(origin string) bool
+		// 默认 GET, POST, HEAD
+		AllowMethods []string
+		// 默认 Content-Type, Authorization, X-Requested-With, X-Request-ID；包含 * 时回显请求的头
+		AllowHeaders  []string
+		ExposeHeaders []string
+		// 允许携带 Cookie；此时回显请求的源，且不能与 AllowOrigins 中的 * 同时使用
+		AllowCredentials bool
+		// 预检结果的缓存时间，0 则不设置
+		MaxAge time.Duration
+	}
+
+	cors struct {
+		o          CORSOptions
+		any        bool
+		origins    map[string]bool
+		wildcards  [][2]string // 前缀, 后缀
+		patterns   []*regexp.Regexp
+		methods    string
+		headers    string
+		anyHeaders bool
+		expose     string
+	}
+)
+
+var (
+	// 任意源都可携带 Cookie 发起请求，等同于关闭同源策略
+	ErrCORSAnyWithCredentials = errors.New("cors: AllowOrigins \"*\" cannot be used with AllowCredentials")
+)
+
+// 跨域中间件
+//
+// 自动应答预检请求（OPTIONS）；需通过 ActionGroup.Use 启用，使其位于 Method 检查之外
+// 全局注册时预检请求会被 Method 拒绝
+// 配置有误时记录错误并忽略有误的部分（* 与 AllowCredentials 同时使用时忽略 *，无法编译的正则被跳过），
+// 需要在启动时报错请使用 NewCORS
+func CORS(o CORSOptions) middleware.MiddewareFunc {
+	c, e := newCORS(o)
+	if e != nil {
+		glg.Error("[CORS] ", e)
+	}
+	return c.middleware()
+}
+
+// 与 CORS 相同，但配置有误时返回 error
+func NewCORS(o CORSOptions) (middleware.MiddewareFunc, error) {
+	c, e := newCORS(o)
+	if e != nil {
+		return nil, e
+	}
+	return c.middleware(), nil
+}
+
+func (c *cors) middleware() middleware.MiddewareFunc {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if origin == "" {
+				f(w, r)
+				return
+			}
+			if !c.allowed(origin) {
+				glg.Warn("[CORS] origin not allowed: ", origin, " ", r.URL.Path)
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				f(w, r)
+				return
+			}
+
+			if c.any {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.o.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if c.expose != "" {
+					h.Set("Access-Control-Expose-Headers", c.expose)
+				}
+				f(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", c.methods)
+			if c.anyHeaders {
+				if rh := r.Header.Get("Access-Control-Request-Headers"); rh != "" {
+					h.Set("Access-Control-Allow-Headers", rh)
+				}
+			} else {
+				h.Set("Access-Control-Allow-Headers", c.headers)
+			}
+			if c.o.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.FormatInt(int64(c.o.MaxAge.Seconds()), 10))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func newCORS(o CORSOptions) (*cors, error) {
+	c := &cors{o: o, origins: map[string]bool{}}
+	var es []error
+	for _, ao := range o.AllowOrigins {
+		ao = strings.ToLower(strings.TrimSpace(ao))
+		switch {
+		case ao == "*" && o.AllowCredentials:
+			es = append(es, ErrCORSAnyWithCredentials)
+		case ao == "*":
+			c.any = true
+		case strings.Contains(ao, "*"):
+			pre, suf, _ := strings.Cut(ao, "*")
+			c.wildcards = append(c.wildcards, [2]string{pre, suf})
+		default:
+			c.origins[ao] = true
+		}
+	}
+	for _, p := range o.AllowOriginPatterns {
+		// 未锚定的 https://.*\.example\.com 会放行 https://x.example.com.evil.com
+		re, e := regexp.Compile("^(?:" + p + ")$")
+		if e != nil {
+			es = append(es, fmt.Errorf("cors: AllowOriginPatterns %q: %w", p, e))
+			continue
+		}
+		c.patterns = append(c.patterns, re)
+	}
+	if len(o.AllowMethods) == 0 {
+		o.AllowMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+	}
+	c.methods = strings.ToUpper(strings.Join(o.AllowMethods, ", "))
+	if len(o.AllowHeaders) == 0 {
+		o.AllowHeaders = []string{"Content-Type", "Authorization", "X-Requested-With", "X-Request-ID"}
+	}
+	for _, hd := range o.AllowHeaders {
+		if hd == "*" {
+			c.anyHeaders = true
+		}
+	}
+	c.headers = strings.Join(o.AllowHeaders, ", ")
+	c.expose = strings.Join(o.ExposeHeaders, ", ")
+	return c, errors.Join(es...)
+}
+
+func (c *cors) allowed(origin string) bool {
+	if c.o.AllowOriginFunc != nil {
+		return c.o.AllowOriginFunc(origin)
+	}
+	if c.any {
+		return true
+	}
+	lo := strings.ToLower(origin)
+	if c.origins[lo] {
+		return true
+	}
+	for _, wc := range c.wildcards {
+		if len(lo) > len(wc[0])+len(wc[1]) && strings.HasPrefix(lo, wc[0]) && strings.HasSuffix(lo, wc[1]) {
+			return true
+		}
+	}
+	for _, p := range c.patterns {
+		if p.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
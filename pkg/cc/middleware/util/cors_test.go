@@ -0,0 +1,132 @@
+package middlewareUtil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func corsDo(h http.HandlerFunc, method, origin string, hdr ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/x", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(hdr); i += 2 {
+		r.Header.Set(hdr[i], hdr[i+1])
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestCORSOrigins(t *testing.T) {
+	mw, e := NewCORS(CORSOptions{
+		AllowOrigins:        []string{"https://a.com", "https://*.b.com"},
+		AllowOriginPatterns: []string{`^https://c[0-9]+\.com$`},
+		AllowCredentials:    true,
+		ExposeHeaders:       []string{"X-Total"},
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	h := mw(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	for origin, ok := range map[string]bool{
+		"https://a.com":      true,
+		"https://x.b.com":    true,
+		"https://.b.com":     false,
+		"https://b.com":      false,
+		"https://c12.com":    true,
+		"https://cx.com":     false,
+		"https://evil.com":   false,
+		"http://a.com":       false,
+		"https://a.com.evil": false,
+	} {
+		w := corsDo(h, http.MethodGet, origin)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: simple request should reach handler, got %d", origin, w.Code)
+		}
+		got := w.Header().Get("Access-Control-Allow-Origin")
+		if ok && (got != origin || w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Access-Control-Expose-Headers") != "X-Total") {
+			t.Errorf("%s: headers %v", origin, w.Header())
+		}
+		if !ok && (got != "" || w.Header().Get("Access-Control-Allow-Credentials") != "") {
+			t.Errorf("%s: should not be allowed, headers %v", origin, w.Header())
+		}
+	}
+}
+
+// 正则须匹配整个源
+func TestCORSPatternAnchored(t *testing.T) {
+	mw, e := NewCORS(CORSOptions{AllowOriginPatterns: []string{`https://.*\.example\.com`, `https://a\.com|https://b\.com`}, AllowCredentials: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	h := mw(func(w http.ResponseWriter, r *http.Request) {})
+	for origin, ok := range map[string]bool{
+		"https://x.example.com":          true,
+		"https://x.example.com.evil.com": false,
+		"http://https://x.example.com":   false,
+		"https://b.com":                  true,
+		"https://a.com.evil.com":         false,
+		"https://evil.b.com":             false,
+	} {
+		if got := corsDo(h, http.MethodGet, origin).Header().Get("Access-Control-Allow-Origin"); (got == origin) != ok {
+			t.Errorf("%s: allowed %q, want %v", origin, got, ok)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	called := false
+	h := CORS(CORSOptions{AllowOrigins: []string{"https://a.com"}, AllowMethods: []string{"get", "put"}})(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	w := corsDo(h, http.MethodOptions, "https://a.com", "Access-Control-Request-Method", "PUT")
+	if w.Code != http.StatusNoContent || called {
+		t.Fatalf("preflight: %d called=%v", w.Code, called)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET, PUT" || w.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Errorf("preflight headers: %v", w.Header())
+	}
+	w = corsDo(h, http.MethodOptions, "https://evil.com", "Access-Control-Request-Method", "PUT")
+	if w.Code != http.StatusForbidden || called {
+		t.Errorf("disallowed preflight: %d called=%v", w.Code, called)
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	h := CORS(CORSOptions{AllowOrigins: []string{"*"}})(func(w http.ResponseWriter, r *http.Request) {})
+	w := corsDo(h, http.MethodGet, "https://whatever.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("headers: %v", w.Header())
+	}
+}
+
+// * 与 AllowCredentials 同时使用时不允许任意源携带 Cookie
+func TestCORSAnyWithCredentials(t *testing.T) {
+	o := CORSOptions{AllowOrigins: []string{"*", "https://a.com"}, AllowCredentials: true}
+	if _, e := NewCORS(o); !errors.Is(e, ErrCORSAnyWithCredentials) {
+		t.Fatalf("NewCORS: %v", e)
+	}
+	h := CORS(o)(func(w http.ResponseWriter, r *http.Request) {})
+	w := corsDo(h, http.MethodGet, "https://evil.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("evil origin reflected: %v", w.Header())
+	}
+	w = corsDo(h, http.MethodGet, "https://a.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://a.com" {
+		t.Errorf("listed origin: %v", w.Header())
+	}
+}
+
+func TestCORSBadPattern(t *testing.T) {
+	o := CORSOptions{AllowOriginPatterns: []string{`(`, `^https://ok\.com$`}}
+	if _, e := NewCORS(o); e == nil {
+		t.Fatal("bad pattern accepted")
+	}
+	h := CORS(o)(func(w http.ResponseWriter, r *http.Request) {})
+	if w := corsDo(h, http.MethodGet, "https://ok.com"); w.Header().Get("Access-Control-Allow-Origin") != "https://ok.com" {
+		t.Errorf("valid pattern ignored: %v", w.Header())
+	}
+}
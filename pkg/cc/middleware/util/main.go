@@ -11,9 +11,11 @@ import (
 )
 
 const (
-	POST = "POST"
-	GET  = "GET"
-	WS   = "WS"
+	POST  = "POST"
+	GET   = "GET"
+	PUT   = "PUT"
+	PATCH = "PATCH"
+	WS    = "WS"
 )
 
 // 输出请求所用时间
@@ -33,6 +35,8 @@ func LogUsedTime() middleware.MiddewareFunc {
 }
 
 // 启用Cookie携带
+//
+// Deprecated: 与 EnableAllowOrigin 同时使用时浏览器会拒绝请求，请使用 CORS 并设置 AllowCredentials
 func EnableCookie() middleware.MiddewareFunc {
 	return func(f http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +47,8 @@ func EnableCookie() middleware.MiddewareFunc {
 }
 
 // 启用跨域（测试中使用）
+//
+// Deprecated: 不处理预检请求，请使用 CORS
 func EnableAllowOrigin() middleware.MiddewareFunc {
 	return func(f http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
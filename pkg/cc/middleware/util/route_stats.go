@@ -0,0 +1,58 @@
+package middlewareUtil
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// 路由的计数
+	RouteStat struct {
+		Path     string
+		Timeouts uint64
+		// 因过载被拒绝的请求
+		Shed uint64
+	}
+
+	routeCounter struct {
+		timeouts atomic.Uint64
+		shed     atomic.Uint64
+	}
+)
+
+var (
+	routeCounters sync.Map // path -> *routeCounter
+)
+
+func counterOf(path string) *routeCounter {
+	if c, ok := routeCounters.Load(path); ok {
+		return c.(*routeCounter)
+	}
+	c, _ := routeCounters.LoadOrStore(path, &routeCounter{})
+	return c.(*routeCounter)
+}
+
+// 记录一次超时
+func RecordTimeout(path string) {
+	counterOf(path).timeouts.Add(1)
+}
+
+// 记录一次过载拒绝
+func RecordShed(path string) {
+	counterOf(path).shed.Add(1)
+}
+
+// 所有有记录的路由，按路径排序
+func RouteStats() []RouteStat {
+	var ss []RouteStat
+	routeCounters.Range(func(k, v interface{}) bool {
+		c := v.(*routeCounter)
+		ss = append(ss, RouteStat{Path: k.(string), Timeouts: c.timeouts.Load(), Shed: c.shed.Load()})
+		return true
+	})
+	sort.Slice(ss, func(i, j int) bool {
+		return ss[i].Path < ss[j].Path
+	})
+	return ss
+}
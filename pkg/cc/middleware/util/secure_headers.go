@@ -0,0 +1,95 @@
+// 安全响应头中间件
+package middlewareUtil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+)
+
+const (
+	HeaderCSP           = "Content-Security-Policy"
+	HeaderCSPReportOnly = "Content-Security-Policy-Report-Only"
+)
+
+// 为空的字段不发送
+type SecureHeadersOptions = config.SecureHeadersConfig
+
+// 添加 HSTS、CSP、X-Content-Type-Options、X-Frame-Options、Referrer-Policy、Permissions-Policy
+//
+// 不传参数时使用 config.SecureHeaders（server.cfg 的 [security_headers] 段，未配置的键取默认值）
+// 路由可用 Route.CSP 覆盖 CSP
+func SecureHeaders(opts ...SecureHeadersOptions) middleware.MiddewareFunc {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			o := config.SecureHeaders
+			if len(opts) > 0 {
+				o = opts[0]
+			}
+			h := w.Header()
+			if o.HSTSMaxAge > 0 && IsHTTPS(r) {
+				v := "max-age=" + strconv.FormatInt(int64(o.HSTSMaxAge.Seconds()), 10)
+				if o.HSTSIncludeSubDomains {
+					v += "; includeSubDomains"
+				}
+				if o.HSTSPreload {
+					v += "; preload"
+				}
+				h.Set("Strict-Transport-Security", v)
+			}
+			if o.CSP != "" {
+				csp := o.CSP
+				if o.CSPReportURI != "" {
+					csp += "; report-uri " + o.CSPReportURI
+				}
+				if o.CSPReportOnly {
+					h.Set(HeaderCSPReportOnly, csp)
+				} else {
+					h.Set(HeaderCSP, csp)
+				}
+			}
+			if o.NoSniff {
+				h.Set("X-Content-Type-Options", "nosniff")
+			}
+			if o.FrameOptions != "" {
+				h.Set("X-Frame-Options", o.FrameOptions)
+			}
+			if o.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", o.ReferrerPolicy)
+			}
+			if o.PermissionsPolicy != "" {
+				h.Set("Permissions-Policy", o.PermissionsPolicy)
+			}
+			f(w, r)
+		}
+	}
+}
+
+// 替换当前响应的 CSP，保留 SecureHeaders 设置的 report-only 模式与 report-uri
+// policy 为空则移除 CSP
+func SetCSP(w http.ResponseWriter, policy string) {
+	h := w.Header()
+	name := HeaderCSP
+	old := h.Get(HeaderCSP)
+	if old == "" && h.Get(HeaderCSPReportOnly) != "" {
+		name, old = HeaderCSPReportOnly, h.Get(HeaderCSPReportOnly)
+	}
+	if policy == "" {
+		h.Del(name)
+		return
+	}
+	for _, d := range strings.Split(old, ";") {
+		if d = strings.TrimSpace(d); strings.HasPrefix(d, "report-uri ") && !strings.Contains(policy, "report-uri") {
+			policy += "; " + d
+		}
+	}
+	h.Set(name, policy)
+}
+
+// 直接的 TLS 连接，或反向代理转发的 HTTPS 请求
+func IsHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
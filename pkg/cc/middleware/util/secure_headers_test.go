@@ -0,0 +1,99 @@
+package middlewareUtil
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+)
+
+func secureDo(h http.HandlerFunc, r *http.Request) http.Header {
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w.Header()
+}
+
+func TestSecureHeadersDefault(t *testing.T) {
+	h := SecureHeaders()(func(w http.ResponseWriter, r *http.Request) {})
+	hd := secureDo(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if hd.Get("Strict-Transport-Security") != "" {
+		t.Errorf("HSTS sent over plain http: %q", hd.Get("Strict-Transport-Security"))
+	}
+	want := map[string]string{
+		HeaderCSP:                config.SecureHeaders.CSP,
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        config.SecureHeaders.ReferrerPolicy,
+		"Permissions-Policy":     config.SecureHeaders.PermissionsPolicy,
+	}
+	for k, v := range want {
+		if hd.Get(k) != v {
+			t.Errorf("%s: got %q, want %q", k, hd.Get(k), v)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-Proto", "HTTPS")
+	if v := secureDo(h, r).Get("Strict-Transport-Security"); v != "max-age=15552000; includeSubDomains" {
+		t.Errorf("HSTS behind proxy: %q", v)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	if secureDo(h, r).Get("Strict-Transport-Security") == "" {
+		t.Error("HSTS not sent over tls")
+	}
+}
+
+func TestSecureHeadersOptions(t *testing.T) {
+	h := SecureHeaders(SecureHeadersOptions{
+		HSTSMaxAge:    time.Hour,
+		HSTSPreload:   true,
+		CSP:           "default-src 'none'",
+		CSPReportOnly: true,
+		CSPReportURI:  "/csp",
+	})(func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	hd := secureDo(h, r)
+	if v := hd.Get("Strict-Transport-Security"); v != "max-age=3600; preload" {
+		t.Errorf("HSTS: %q", v)
+	}
+	if hd.Get(HeaderCSP) != "" || hd.Get(HeaderCSPReportOnly) != "default-src 'none'; report-uri /csp" {
+		t.Errorf("CSP: %v", hd)
+	}
+	for _, k := range []string{"X-Content-Type-Options", "X-Frame-Options", "Referrer-Policy", "Permissions-Policy"} {
+		if _, ok := hd[k]; ok {
+			t.Errorf("empty option %s sent", k)
+		}
+	}
+}
+
+func TestSetCSP(t *testing.T) {
+	cases := []struct {
+		opts   SecureHeadersOptions
+		policy string
+		name   string
+		want   string
+	}{
+		{SecureHeadersOptions{CSP: "default-src 'self'"}, "img-src *", HeaderCSP, "img-src *"},
+		{SecureHeadersOptions{CSP: "default-src 'self'", CSPReportURI: "/r"}, "img-src *", HeaderCSP, "img-src *; report-uri /r"},
+		{SecureHeadersOptions{CSP: "default-src 'self'", CSPReportURI: "/r"}, "img-src *; report-uri /x", HeaderCSP, "img-src *; report-uri /x"},
+		{SecureHeadersOptions{CSP: "default-src 'self'", CSPReportOnly: true}, "img-src *", HeaderCSPReportOnly, "img-src *"},
+		{SecureHeadersOptions{CSP: "default-src 'self'"}, "", HeaderCSP, ""},
+	}
+	for i, c := range cases {
+		h := SecureHeaders(c.opts)(func(w http.ResponseWriter, r *http.Request) {
+			SetCSP(w, c.policy)
+		})
+		hd := secureDo(h, httptest.NewRequest(http.MethodGet, "/", nil))
+		if hd.Get(c.name) != c.want {
+			t.Errorf("%d: %s = %q, want %q", i, c.name, hd.Get(c.name), c.want)
+		}
+		if c.want == "" && (hd.Get(HeaderCSP) != "" || hd.Get(HeaderCSPReportOnly) != "") {
+			t.Errorf("%d: CSP not removed: %v", i, hd)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package cc
+
+/**
+基于角色的权限控制，取代 [dm_whitelist] god_id 这种写死的管理员判断
+
+	p, _ := rbac.LoadFile("rbac.cfg") // 或 cc/rbac/sqlite 的 sqlite.Load("")
+	cc.SetPolicy(p)
+
+	cc.AddActionGroup("/v1/article", func(a cc.ActionGroup) error {
+		a.GET("/get", ...)
+		a.POST("/edit", ...).Require("article:write")
+		a = a.Require("article:admin")
+		a.POST("/delete", ...)
+		return nil
+	})
+
+角色默认来自 JWT 的 roles（数组）或 role（字符串）声明，以及 API key 中 role: 开头的 scope
+可通过 SetRoleResolver 自定义
+*/
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/rbac"
+)
+
+var (
+	rbacPolicy   atomic.Pointer[rbac.Policy]
+	roleResolver atomic.Pointer[func(r *http.Request) []string]
+)
+
+// 设置全局的权限策略，重新加载时可再次调用或使用 Policy.Replace
+func SetPolicy(p *rbac.Policy) {
+	rbacPolicy.Store(p)
+}
+
+// 自定义请求的角色解析
+func SetRoleResolver(f func(r *http.Request) []string) {
+	roleResolver.Store(&f)
+}
+
+// 默认的角色解析
+func DefaultRoles(r *http.Request) []string {
+	var roles []string
+	if c := ClaimsFrom(r.Context()); c != nil {
+		var one string
+		if !c.Get("roles", &roles) && c.Get("role", &one) && one != "" {
+			roles = []string{one}
+		}
+	} else if k := APIKeyFrom(r.Context()); k != nil {
+		for _, s := range k.Scopes {
+			if role, ok := strings.CutPrefix(s, "role:"); ok {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// 请求的角色
+func RolesOf(r *http.Request) []string {
+	if f := roleResolver.Load(); f != nil {
+		return (*f)(r)
+	}
+	return DefaultRoles(r)
+}
+
+// 当前请求的角色
+func (R ActionPackage) Roles() []string {
+	return RolesOf(R.R)
+}
+
+// 当前请求是否拥有权限 perm，用于 handler 内的细粒度判断
+func (R ActionPackage) Can(perm string) bool {
+	p := rbacPolicy.Load()
+	return p != nil && p.Allowed(R.Roles(), perm)
+}
+
+// 之后注册的路由都要求已认证，并拥有全部权限
+func (a ActionGroup) Require(perms ...string) ActionGroup {
+	a.auth = a.auth.withPerms(perms)
+	return a
+}
+
+// 该路由要求已认证，并拥有全部权限，可与路由组的要求叠加
+func (rt *Route) Require(perms ...string) *Route {
+	rt.auth = rt.auth.withPerms(perms)
+	return rt
+}
+
+func (ar *authRule) withPerms(perms []string) *authRule {
+	n := &authRule{}
+	if ar != nil {
+		n.scopes = ar.scopes
+		n.perms = ar.perms
+	}
+	n.perms = append(append([]string(nil), n.perms...), perms...)
+	return n
+}
+
+// 未设置策略时拒绝所有需要权限的请求
+func (rt *Route) checkPermissions(r *http.Request) error {
+	if len(rt.auth.perms) == 0 {
+		return nil
+	}
+	p := rbacPolicy.Load()
+	if p == nil {
+		LoggerFrom(r.Context()).Warn("[rbac] policy not set, denying", r.URL.Path)
+		return ErrForbidden("permission denied")
+	}
+	roles := RolesOf(r)
+	for _, perm := range rt.auth.perms {
+		if !p.Allowed(roles, perm) {
+			return ErrForbidden("permission denied: " + perm)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// 基于角色的权限控制
+//
+// 权限为任意字符串，约定为 资源:操作，如 article:write
+// 授予 article:* 即拥有 article 下的所有操作，授予 * 即拥有所有权限
+package rbac
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type (
+	// 角色与权限表，可并发使用，Replace 用于重新加载
+	Policy struct {
+		mu       sync.RWMutex
+		perms    map[string]map[string]bool
+		inherits map[string][]string
+	}
+)
+
+func NewPolicy() *Policy {
+	return &Policy{perms: map[string]map[string]bool{}, inherits: map[string][]string{}}
+}
+
+// 为角色授予权限
+func (p *Policy) Grant(role string, perms ...string) *Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.perms[role] == nil {
+		p.perms[role] = map[string]bool{}
+	}
+	for _, pm := range perms {
+		if pm = strings.TrimSpace(pm); pm != "" {
+			p.perms[role][pm] = true
+		}
+	}
+	return p
+}
+
+// 角色继承 parents 的所有权限
+func (p *Policy) Inherit(role string, parents ...string) *Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inherits[role] = append(p.inherits[role], parents...)
+	return p
+}
+
+// 用 o 的内容替换当前策略
+// 复制 o 的内容，之后对 o 或 p 的修改互不影响
+func (p *Policy) Replace(o *Policy) {
+	o.mu.RLock()
+	perms := make(map[string]map[string]bool, len(o.perms))
+	for role, ps := range o.perms {
+		perms[role] = maps.Clone(ps)
+	}
+	inherits := make(map[string][]string, len(o.inherits))
+	for role, parents := range o.inherits {
+		inherits[role] = slices.Clone(parents)
+	}
+	o.mu.RUnlock()
+	p.mu.Lock()
+	p.perms, p.inherits = perms, inherits
+	p.mu.Unlock()
+}
+
+// roles 中任一角色（含继承）拥有 perm 时返回 true
+func (p *Policy) Allowed(roles []string, perm string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	seen := map[string]bool{}
+	var check func(role string) bool
+	check = func(role string) bool {
+		if seen[role] {
+			return false
+		}
+		seen[role] = true
+		if ps := p.perms[role]; ps != nil && match(ps, perm) {
+			return true
+		}
+		for _, parent := range p.inherits[role] {
+			if check(parent) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, r := range roles {
+		if check(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// 角色拥有的所有权限（含继承）
+func (p *Policy) Permissions(role string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	res := map[string]bool{}
+	seen := map[string]bool{}
+	var walk func(role string)
+	walk = func(role string) {
+		if seen[role] {
+			return
+		}
+		seen[role] = true
+		for pm := range p.perms[role] {
+			res[pm] = true
+		}
+		for _, parent := range p.inherits[role] {
+			walk(parent)
+		}
+	}
+	walk(role)
+	ps := make([]string, 0, len(res))
+	for pm := range res {
+		ps = append(ps, pm)
+	}
+	return ps
+}
+
+func match(granted map[string]bool, perm string) bool {
+	if granted["*"] || granted[perm] {
+		return true
+	}
+	// article:comment:delete 依次匹配 article:comment:* 与 article:*
+	for i := strings.LastIndexByte(perm, ':'); i > 0; i = strings.LastIndexByte(perm[:i], ':') {
+		if granted[perm[:i]+":*"] {
+			return true
+		}
+	}
+	return false
+}
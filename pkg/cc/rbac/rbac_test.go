@@ -0,0 +1,83 @@
+package rbac
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	p := NewPolicy().
+		Grant("viewer", "article:read").
+		Grant("editor", "article:write", "comment:*").
+		Grant("root", "*").
+		Inherit("editor", "viewer").
+		Inherit("viewer", "editor") // 循环继承不应死循环
+
+	cases := []struct {
+		roles []string
+		perm  string
+		want  bool
+	}{
+		{[]string{"viewer"}, "article:read", true},
+		{[]string{"viewer"}, "article:write", true},
+		{[]string{"editor"}, "article:read", true},
+		{[]string{"editor"}, "comment:reply:delete", true},
+		{[]string{"editor"}, "user:delete", false},
+		{[]string{"root"}, "user:delete", true},
+		{[]string{"guest"}, "article:read", false},
+		{nil, "article:read", false},
+	}
+	for _, c := range cases {
+		if got := p.Allowed(c.roles, c.perm); got != c.want {
+			t.Errorf("Allowed(%v, %q) = %v, want %v", c.roles, c.perm, got, c.want)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.cfg")
+	_ = os.WriteFile(path, []byte("[role.viewer]\npermissions = article:read\n[role.editor]\npermissions = article:write, article:publish\ninherits = viewer\n"), 0600)
+	p, e := LoadFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !p.Allowed([]string{"editor"}, "article:read") || !p.Allowed([]string{"editor"}, "article:publish") {
+		t.Error("editor should inherit viewer and have article:publish")
+	}
+	if p.Allowed([]string{"viewer"}, "article:write") {
+		t.Error("viewer should not have article:write")
+	}
+}
+
+// Replace 复制内容，之后修改来源不影响已替换的策略
+func TestReplace(t *testing.T) {
+	p := NewPolicy().Grant("a", "x")
+	src := NewPolicy().Grant("b", "y").Inherit("b", "a")
+	p.Replace(src)
+	if p.Allowed([]string{"a"}, "x") || !p.Allowed([]string{"b"}, "y") {
+		t.Fatal("replace did not take effect")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			src.Grant("b", "z"+strconv.Itoa(i)).Inherit("b", "c")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			p.Allowed([]string{"b"}, "z1")
+			p.Permissions("b")
+		}
+	}()
+	wg.Wait()
+	if p.Allowed([]string{"b"}, "z1") {
+		t.Error("grant on source leaked into replaced policy")
+	}
+}
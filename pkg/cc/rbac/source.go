@@ -0,0 +1,31 @@
+package rbac
+
+import (
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// 从 ini 文件加载，每个角色一段：
+//
+//	[role.editor]
+//	permissions = article:read, article:write
+//	inherits    = viewer
+func LoadFile(path string) (*Policy, error) {
+	cfg, e := ini.Load(path)
+	if e != nil {
+		return nil, e
+	}
+	p := NewPolicy()
+	for _, sec := range cfg.Sections() {
+		role, ok := strings.CutPrefix(sec.Name(), "role.")
+		if !ok || role == "" {
+			continue
+		}
+		p.Grant(role, sec.Key("permissions").Strings(",")...)
+		if in := sec.Key("inherits").Strings(","); len(in) > 0 {
+			p.Inherit(role, in...)
+		}
+	}
+	return p, nil
+}
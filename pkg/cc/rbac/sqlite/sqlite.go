@@ -0,0 +1,67 @@
+// 从 SQLite 加载 RBAC 策略，依赖 cgo（go-sqlite3），不需要时无需引入
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+	"github.com/cyf-gh/ccgo/pkg/cc/rbac"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS cc_role_permission (
+	role       TEXT NOT NULL,
+	permission TEXT NOT NULL,
+	PRIMARY KEY (role, permission)
+);
+CREATE TABLE IF NOT EXISTS cc_role_inherit (
+	role   TEXT NOT NULL,
+	parent TEXT NOT NULL,
+	PRIMARY KEY (role, parent)
+);`
+
+// 从 cc_role_permission 与 cc_role_inherit 表加载，表不存在时创建
+// path 为空时使用 server.cfg 的 [sqlite3] path
+func Load(path string) (*rbac.Policy, error) {
+	if path == "" {
+		path = config.SqlitePath
+	}
+	db, e := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if e != nil {
+		return nil, e
+	}
+	defer db.Close()
+	if _, e = db.Exec(sqliteSchema); e != nil {
+		return nil, e
+	}
+	p := rbac.NewPolicy()
+	if e = eachRow(db, `SELECT role, permission FROM cc_role_permission`, func(role, perm string) {
+		p.Grant(role, perm)
+	}); e != nil {
+		return nil, e
+	}
+	if e = eachRow(db, `SELECT role, parent FROM cc_role_inherit`, func(role, parent string) {
+		p.Inherit(role, parent)
+	}); e != nil {
+		return nil, e
+	}
+	return p, nil
+}
+
+func eachRow(db *sql.DB, q string, fn func(a, b string)) error {
+	rows, e := db.Query(q)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a, b string
+		if e := rows.Scan(&a, &b); e != nil {
+			return e
+		}
+		fn(a, b)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.db")
+	// 首次加载时建表
+	p, e := Load(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(p.Permissions("editor")) != 0 {
+		t.Fatal("empty database should have no grants")
+	}
+	db, e := sql.Open("sqlite3", path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = db.Exec(`INSERT INTO cc_role_permission (role, permission) VALUES
+		('viewer', 'article:read'), ('editor', 'article:write'), ('editor', 'comment:*');
+		INSERT INTO cc_role_inherit (role, parent) VALUES ('editor', 'viewer');`)
+	_ = db.Close()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if p, e = Load(path); e != nil {
+		t.Fatal(e)
+	}
+	got := p.Permissions("editor")
+	sort.Strings(got)
+	if want := []string{"article:read", "article:write", "comment:*"}; !slices.Equal(got, want) {
+		t.Errorf("editor permissions: %v, want %v", got, want)
+	}
+	if !p.Allowed([]string{"editor"}, "comment:delete") || p.Allowed([]string{"viewer"}, "article:write") {
+		t.Error("loaded grants not applied")
+	}
+}
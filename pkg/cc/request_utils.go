@@ -1,83 +1,62 @@
 package cc
 
 import (
-	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
-	"io/ioutil"
+	"errors"
 	"net/http"
-	"net/url"
-	"time"
 )
 
-func GetByProxy( webUrl, proxyUrl string ) ( respStr string, e error ) {
-	proxy, e := url.Parse(proxyUrl)
-	tr := &http.Transport{
-		Proxy: http.ProxyURL(proxy),
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
+// 发出请求前对请求的修改，例如 WithSignature
+type RequestOption func( r *http.Request ) error
 
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Second * 15,
+func applyOptions( r *http.Request, opts []RequestOption ) ( e error ) {
+	for _, o := range opts {
+		if e = o( r ); e != nil { return }
 	}
-	resp, e := client.Get(webUrl)
-
-	body, e := ioutil.ReadAll(resp.Body)
-	respStr = string(body)
-	defer resp.Body.Close()
 	return
 }
 
-func PostByProxy( webUrl string, body interface{}, v interface{}, proxyUrl string) ( e error ) {
-	proxy, _ := url.Parse(proxyUrl)
-	tr := &http.Transport{
-		Proxy:           http.ProxyURL(proxy),
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
+// 以下为旧接口，不区分状态码：状态码 >= 400 时仍返回 body 或按 json 解析，不返回 *StatusError
+// 需要检查状态码时使用 Client 的同名方法
 
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   time.Second * 15,
-	}
-	jn, e := json.Marshal( body ); if e != nil { return e }
-	b := bytes.NewBuffer(jn)
-	req, e := http.NewRequest("POST", webUrl, b ); if e != nil { return e }
-	resp, e := client.Do(req); if e != nil { return e }
+// 经由代理 proxyUrl 的 http get request
+func GetByProxy( webUrl, proxyUrl string, opts ...RequestOption ) ( respStr string, e error ) {
+	b, e := legacySend( proxyClient( proxyUrl ), http.MethodGet, webUrl, nil, opts )
+	return string( b ), e
+}
 
-	defer resp.Body.Close()
-	bbb, e := ioutil.ReadAll( resp.Body ); if e != nil { return e }
-	e = json.Unmarshal( bbb, v ); if e != nil { return e }
-	return
+// 经由代理 proxyUrl 的 http post request with json
+func PostByProxy( webUrl string, body interface{}, v interface{}, proxyUrl string, opts ...RequestOption ) ( e error ) {
+	return legacyPostJ( proxyClient( proxyUrl ), webUrl, body, v, opts )
 }
 
 // http get request
-func Get( url string ) ( respStr string, e error ) {
-	resp, e := http.Get( url ); if e != nil { return }
-	defer resp.Body.Close()
-	body, e := ioutil.ReadAll(resp.Body); if e != nil { return }
-	respStr = string( body )
-	return
+func Get( url string, opts ...RequestOption ) ( respStr string, e error ) {
+	b, e := legacySend( DefaultClient, http.MethodGet, url, nil, opts )
+	return string( b ), e
 }
 
 // http get request with json
-func GetJ( url string, v interface{} ) ( e error ) {
-	resp, e := http.Get( url ); if e != nil { return }
-	defer resp.Body.Close()
-	b, e := ioutil.ReadAll( resp.Body ); if e != nil { return e }
-	e = json.Unmarshal( b, v ); if e != nil { return e }
-	return
+func GetJ( url string, v interface{}, opts ...RequestOption ) ( e error ) {
+	b, e := legacySend( DefaultClient, http.MethodGet, url, nil, opts ); if e != nil { return }
+	return json.Unmarshal( b, v )
 }
 
 // http post request with json
-func PostJ( u string, body interface{}, v interface{} ) ( e error ) {
-	client := &http.Client{}
-	jn, e := json.Marshal( body ); if e != nil { return e }
-	b := bytes.NewBuffer(jn)
-	req, e := http.NewRequest("POST", u, b ); if e != nil { return e }
-	resp, e := client.Do(req); if e != nil { return e }
-	defer resp.Body.Close()
-	bbb, e := ioutil.ReadAll( resp.Body ); if e != nil { return e }
-	e = json.Unmarshal( bbb, v ); if e != nil { return e }
+func PostJ( u string, body interface{}, v interface{}, opts ...RequestOption ) ( e error ) {
+	return legacyPostJ( DefaultClient, u, body, v, opts )
+}
+
+func legacySend( c *Client, method, u string, body []byte, opts []RequestOption ) ( b []byte, e error ) {
+	b, e = c.send( context.Background(), method, u, body, opts )
+	var se *StatusError
+	if errors.As( e, &se ) { e = nil }
 	return
 }
+
+func legacyPostJ( c *Client, u string, body interface{}, v interface{}, opts []RequestOption ) ( e error ) {
+	jn, e := json.Marshal( body ); if e != nil { return }
+	b, e := legacySend( c, http.MethodPost, u, jn, opts ); if e != nil { return }
+	return json.Unmarshal( b, v )
+}
@@ -0,0 +1,190 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/limiter"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+type (
+	// 已注册的路由
+	// 由 ActionGroup.GET/POST/... 返回，可链式设置路由级别的选项
+	Route struct {
+		Method     string
+		Path       string
+		Deprecated bool
+		// 由 Handle 注册时记录，用于文档生成
+		ReqType  reflect.Type
+		RespType reflect.Type
+
+		timeout time.Duration
+		maxBody int64
+		csp     *string
+		auth    *authRule
+		cache   *routeCache
+		limiter *limiter.Limiter
+		// 由 RequireIfMatch 设置
+		requireIfMatch bool
+	}
+)
+
+var (
+	routes       []*Route
+	routesByPath = map[string]*Route{}
+	routesMu     sync.RWMutex
+)
+
+func (a ActionGroup) route(method, path string) *Route {
+	rt := &Route{Method: method, Path: a.Path + path, Deprecated: a.Deprecate, auth: a.auth}
+	routesMu.Lock()
+	routes = append(routes, rt)
+	routesByPath[rt.Path] = rt
+	routesMu.Unlock()
+	return rt
+}
+
+// 请求匹配的路由，用于在中间件中取得路由的选项；不是经 ActionGroup 注册的路由时返回 nil
+func routeOf(r *http.Request) *Route {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+	return routesByPath[r.Pattern]
+}
+
+// 所有已注册的路由，按路径排序
+func Routes() []*Route {
+	routesMu.RLock()
+	rs := make([]*Route, len(routes))
+	copy(rs, routes)
+	routesMu.RUnlock()
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].Path < rs[j].Path
+	})
+	return rs
+}
+
+// 设置路由超时，超时后请求的 context 被取消并立即返回 HerTimeout，见 timeout.go
+func (rt *Route) Timeout(d time.Duration) *Route {
+	rt.timeout = d
+	return rt
+}
+
+// 设置路由的 body 上限，单位字节，覆盖 server.cfg 中的 [http] max_body_size
+// n < 0 为不限制
+func (rt *Route) MaxBody(n int64) *Route {
+	rt.maxBody = n
+	return rt
+}
+
+// 设置路由的 CSP，覆盖 SecureHeaders 中间件的默认值，为空则不发送
+func (rt *Route) CSP(policy string) *Route {
+	rt.csp = &policy
+	return rt
+}
+
+// 注入请求上下文，并在路由超时或服务器关闭时取消
+func (rt *Route) begin(w http.ResponseWriter, r *http.Request) (*http.Request, context.CancelFunc) {
+	r = withRequestScope(w, r)
+	limitBody(w, r, rt.maxBody)
+	if rt.csp != nil {
+		mwu.SetCSP(w, *rt.csp)
+	}
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if rt.timeout > 0 {
+		ctx, cancel = context.WithTimeout(r.Context(), rt.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(r.Context())
+	}
+	stop := context.AfterFunc(serverCtx, cancel)
+	return r.WithContext(ctx), func() {
+		stop()
+		cancel()
+	}
+}
+
+// 构造 ActionPackage 并调用 handler
+// handler 已自行写出响应（如 ServeFile）时 ok 为 false，此时不应再返回 HER
+func (rt *Route) call(w http.ResponseWriter, r *http.Request, handler ActionFunc) (her HttpErrReturn, status StatusCode, ok bool) {
+	r, cancel := rt.begin(w, r)
+	defer cancel()
+
+	if e := csrfVerifyPending(r); e != nil {
+		her, status = HerFromError(e)
+		return her, status, true
+	}
+	if e := rt.authorize(r); e != nil {
+		her, status = HerFromError(e)
+		return her, status, true
+	}
+	if rt.requireIfMatch && r.Header.Get("If-Match") == "" {
+		her, status = HerFromError(ErrPreconditionRequired())
+		return her, status, true
+	}
+	run := func(w http.ResponseWriter, r *http.Request) (HttpErrReturn, StatusCode, bool) {
+		return rt.run(w, r, handler)
+	}
+	if rt.limiter != nil {
+		// 缓存命中不占用并发数
+		run = limitRun(rt.limiter, rt.Path, run)
+	}
+	if rt.cache != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		cached := run
+		run = func(w http.ResponseWriter, r *http.Request) (HttpErrReturn, StatusCode, bool) {
+			return rt.cache.serve(w, r, rt, cached)
+		}
+	}
+	if rt.timeout <= 0 {
+		return run(w, r)
+	}
+	// handler 在另一个 goroutine 中执行，超时后不再读取其结果
+	type result struct {
+		her    HttpErrReturn
+		status StatusCode
+		ok     bool
+	}
+	var res result
+	if runWithTimeout(w, r, rt.Path, func(tw http.ResponseWriter) {
+		res.her, res.status, res.ok = run(tw, r)
+	}) {
+		return her, status, false
+	}
+	return res.her, res.status, res.ok
+}
+
+func (rt *Route) run(w http.ResponseWriter, r *http.Request, handler ActionFunc) (her HttpErrReturn, status StatusCode, ok bool) {
+	her, status = handler(ActionPackage{R: r, W: &w})
+	st := stateFrom(r.Context())
+	if st.written {
+		st.mu.Lock()
+		stream := st.stream
+		st.mu.Unlock()
+		if stream != nil {
+			stream.finish(her.ErrCod == err_code.ERR_OK)
+		}
+		return her, status, false
+	}
+	st.mu.Lock()
+	tooLarge := st.bodyTooLarge
+	st.mu.Unlock()
+	if tooLarge && her.ErrCod != err_code.ERR_OK {
+		// 例如 GetBodyUnmarshal 出错后返回了 HerArgInvalid
+		her, status = HerBodyTooLarge()
+	}
+	if e := r.Context().Err(); e != nil {
+		if errors.Is(e, context.DeadlineExceeded) || IsShuttingDown() {
+			LoggerFrom(r.Context()).Warn("[action]", rt.Path, "canceled:", e)
+			her, status = HerFromError(e)
+		}
+	}
+	return her, status, true
+}
@@ -0,0 +1,390 @@
+package cc
+
+/**
+GET 路由的响应缓存
+
+	a.GET("/rank", rank).Cache(cc.CacheOptions{TTL: time.Minute, StaleTTL: 5 * time.Minute, KeyQuery: []string{"page"}})
+	a.GET_DO("/feed", feed).Cache(cc.CacheOptions{TTL: 30 * time.Second, Store: kv.NewRedis(config.RedisCfg)})
+
+- 只缓存 ErrCod 为 ERR_OK 且状态码为 200 的 HER，不缓存自行写出的响应（ServeFile、SSE 等）
+- 过期但仍在 StaleTTL 内时先返回旧值，并在后台重新执行 handler
+- 同一个 key 的并发未命中只执行一次 handler
+- 认证与权限检查（RequireAuth、Require）在读取缓存之前进行；需要认证的路由默认按调用方缓存，Cache-Control 为 private
+- 按调用方缓存时以 API key、JWT subject 或 session 区分，三者都没有的请求不使用缓存
+- 后台刷新使用路由的超时，未设置时为 30 秒
+- 保存的是 HER 序列化后的结果，命中时原样写出，ETag 与首次响应相同
+
+命令行 cache-purge [path] 清除指定路由或所有路由的缓存
+*/
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/cli"
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/kv"
+)
+
+const (
+	HeaderXCache = "X-Cache"
+)
+
+type (
+	CacheOptions struct {
+		// 默认 DefaultCacheStore
+		Store kv.Store
+		// 新鲜期，默认 1 分钟
+		TTL time.Duration
+		// 过期后仍可返回旧值并后台刷新的时长，0 为不启用
+		StaleTTL time.Duration
+		// 参与缓存 key 的 query 参数，nil 为全部参数，空切片为不使用 query
+		KeyQuery []string
+		// 参与缓存 key 的请求头，同时写入 Vary
+		KeyHeaders []string
+		// 按调用方（API key、JWT subject 或 session）分别缓存，Cache-Control 使用 private
+		// 无法确定调用方的请求不使用缓存；不按 IP 区分，同一出口 IP 后的用户会看到彼此的结果
+		// 需要认证（RequireAuth、Require）的路由总是 private，且默认按调用方缓存
+		PerUser bool
+		// 需要认证的路由结果与调用方无关时设置，所有调用方共用缓存
+		Shared bool
+	}
+
+	routeCache struct {
+		o      CacheOptions
+		flight flightGroup
+	}
+
+	cacheEntry struct {
+		// HER 序列化后的结果
+		Body   []byte `json:"b"`
+		Stored int64  `json:"t"`
+	}
+
+	// 合并相同 key 的并发调用
+	flightGroup struct {
+		mu sync.Mutex
+		m  map[string]*flightCall
+	}
+
+	flightCall struct {
+		wg sync.WaitGroup
+		e  *cacheEntry
+	}
+
+	// 后台刷新时丢弃 handler 直接写出的内容
+	discardWriter struct {
+		h http.Header
+	}
+)
+
+var (
+	// 未指定 Store 的路由共用的缓存
+	DefaultCacheStore kv.Store = kv.NewMemory()
+
+	// 未设置超时的路由后台刷新的超时
+	cacheRevalidateTimeout = 30 * time.Second
+)
+
+func init() {
+	cli.Register("cache-purge", &cli.CliFuncPack{F: func(args []string) error {
+		path := ""
+		if len(args) > 0 {
+			path = args[0]
+		}
+		n, e := PurgeCache(context.Background(), path)
+		if e != nil {
+			return e
+		}
+		fmt.Println("purged routes:", n)
+		return nil
+	}, Desc: "Purge response cache: [path]", Group: "cache"})
+}
+
+// 为 GET、GET_DO 路由启用响应缓存
+// 缓存的是 handler 返回的 HER，handler 自行设置的响应头不会被缓存
+func (rt *Route) Cache(o CacheOptions) *Route {
+	if o.Store == nil {
+		o.Store = DefaultCacheStore
+	}
+	if o.TTL <= 0 {
+		o.TTL = time.Minute
+	}
+	// 不修改调用方的切片
+	o.KeyQuery = slices.Clone(o.KeyQuery)
+	o.KeyHeaders = slices.Clone(o.KeyHeaders)
+	for i, h := range o.KeyHeaders {
+		o.KeyHeaders[i] = http.CanonicalHeaderKey(h)
+	}
+	rt.cache = &routeCache{o: o}
+	return rt
+}
+
+// 清除路由的缓存，path 为空时清除所有启用缓存的路由，返回清除的路由数
+// 通过递增代数使旧的缓存 key 失效，旧数据随 TTL 过期
+func PurgeCache(ctx context.Context, path string) (int, error) {
+	n := 0
+	for _, rt := range Routes() {
+		if rt.cache == nil || (path != "" && rt.Path != path) {
+			continue
+		}
+		gen := rt.cache.generation(ctx, rt.Path)
+		if e := rt.cache.o.Store.Set(ctx, "cache-gen:"+rt.Path, []byte(strconv.FormatInt(gen+1, 10)), 0); e != nil {
+			return n, e
+		}
+		n++
+	}
+	if path != "" && n == 0 {
+		return 0, errors.New("no cached route: " + path)
+	}
+	return n, nil
+}
+
+func (c *routeCache) generation(ctx context.Context, path string) int64 {
+	b, e := c.o.Store.Get(ctx, "cache-gen:"+path)
+	if e != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(string(b), 10, 64)
+	return n
+}
+
+// 按调用方分别缓存
+func (c *routeCache) perUser(rt *Route) bool {
+	return c.o.PerUser || (rt.auth != nil && !c.o.Shared)
+}
+
+// 按调用方缓存时的调用方，无法确定时为空
+func cacheUser(r *http.Request) string {
+	if k := APIKeyFrom(r.Context()); k != nil {
+		return "k:" + k.ID
+	}
+	if c := ClaimsFrom(r.Context()); c != nil && c.Subject != "" {
+		return "u:" + c.Subject
+	}
+	if id := sessionIDOf(r); id != "" {
+		return "s:" + id
+	}
+	return ""
+}
+
+// user 为空时所有调用方共用
+func (c *routeCache) key(r *http.Request, path, user string) string {
+	var sb strings.Builder
+	sb.WriteString(r.URL.Path)
+	q := r.URL.Query()
+	keys := c.o.KeyQuery
+	if keys == nil {
+		for k := range q {
+			keys = append(keys, k)
+		}
+	}
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			sb.WriteString("\nq:" + url.QueryEscape(k) + "=" + url.QueryEscape(v))
+		}
+	}
+	for _, h := range c.o.KeyHeaders {
+		sb.WriteString("\nh:" + h + "=" + strings.Join(r.Header.Values(h), ","))
+	}
+	if user != "" {
+		sb.WriteString("\nu:" + user)
+	}
+	sum := sha256.Sum256([]byte(sb.String()))
+	gen := c.generation(r.Context(), path)
+	return "cache:" + path + ":" + strconv.FormatInt(gen, 10) + ":" + hex.EncodeToString(sum[:16])
+}
+
+func (c *routeCache) load(ctx context.Context, key string) *cacheEntry {
+	b, e := c.o.Store.Get(ctx, key)
+	if e != nil {
+		if !errors.Is(e, kv.ErrNotFound) {
+			LoggerFrom(ctx).Warn("[cache]", e)
+		}
+		return nil
+	}
+	var ce cacheEntry
+	if json.Unmarshal(b, &ce) != nil || len(ce.Body) == 0 {
+		return nil
+	}
+	return &ce
+}
+
+// 保存的 HER，写出时使用保存的序列化结果
+func (ce *cacheEntry) her() HttpErrReturn {
+	var her HttpErrReturn
+	_ = json.Unmarshal(ce.Body, &her)
+	her.raw = string(ce.Body)
+	return her
+}
+
+func (c *routeCache) save(ctx context.Context, key string, ce *cacheEntry) {
+	b, _ := json.Marshal(ce)
+	if e := c.o.Store.Set(context.WithoutCancel(ctx), key, b, c.o.TTL+c.o.StaleTTL); e != nil {
+		LoggerFrom(ctx).Warn("[cache] save:", e)
+	}
+}
+
+// 执行 handler，结果可缓存时保存
+func (c *routeCache) fill(w http.ResponseWriter, r *http.Request, key string, run func(http.ResponseWriter, *http.Request) (HttpErrReturn, StatusCode, bool)) (*cacheEntry, HttpErrReturn, StatusCode, bool) {
+	her, status, ok := run(w, r)
+	if !ok || status != http.StatusOK || her.ErrCod != err_code.ERR_OK || r.Context().Err() != nil {
+		return nil, her, status, ok
+	}
+	bs, e := her.marshal()
+	if e != nil {
+		return nil, her, status, ok
+	}
+	ce := &cacheEntry{Body: bs, Stored: time.Now().UnixNano()}
+	c.save(r.Context(), key, ce)
+	return ce, her, status, ok
+}
+
+// 由 Route.call 在认证之后调用，只为可缓存的结果设置 Cache-Control
+func (c *routeCache) serve(w http.ResponseWriter, r *http.Request, rt *Route, run func(http.ResponseWriter, *http.Request) (HttpErrReturn, StatusCode, bool)) (HttpErrReturn, StatusCode, bool) {
+	perUser := c.perUser(rt)
+	var user string
+	if perUser {
+		if user = cacheUser(r); user == "" {
+			return run(w, r)
+		}
+	}
+	her, status, ok := c.lookup(w, r, rt, c.key(r, rt.Path, user), run)
+	if ok && status == http.StatusOK && her.ErrCod == err_code.ERR_OK {
+		c.setHeaders(w, perUser || rt.auth != nil)
+	}
+	return her, status, ok
+}
+
+func (c *routeCache) lookup(w http.ResponseWriter, r *http.Request, rt *Route, key string, run func(http.ResponseWriter, *http.Request) (HttpErrReturn, StatusCode, bool)) (HttpErrReturn, StatusCode, bool) {
+	if ce := c.load(r.Context(), key); ce != nil {
+		age := time.Since(time.Unix(0, ce.Stored))
+		w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+		if age < c.o.TTL {
+			w.Header().Set(HeaderXCache, "HIT")
+			return ce.her(), http.StatusOK, true
+		}
+		if age < c.o.TTL+c.o.StaleTTL {
+			w.Header().Set(HeaderXCache, "STALE")
+			c.revalidate(r, rt, key, run)
+			return ce.her(), http.StatusOK, true
+		}
+		w.Header().Del("Age")
+	}
+	w.Header().Set(HeaderXCache, "MISS")
+
+	fc, leader := c.flight.join(key)
+	if !leader {
+		fc.wg.Wait()
+		if fc.e != nil {
+			return fc.e.her(), http.StatusOK, true
+		}
+		// 首个请求的结果不可缓存，自行执行
+		return run(w, r)
+	}
+	var (
+		her    HttpErrReturn
+		status StatusCode
+		ok     bool
+	)
+	defer func() {
+		c.flight.done(key, fc)
+	}()
+	fc.e, her, status, ok = c.fill(w, r, key, run)
+	return her, status, ok
+}
+
+// 后台刷新，同一个 key 同时只有一次
+// 超时后即释放该 key，handler 未返回也不阻塞之后的刷新
+func (c *routeCache) revalidate(r *http.Request, rt *Route, key string, run func(http.ResponseWriter, *http.Request) (HttpErrReturn, StatusCode, bool)) {
+	fc, leader := c.flight.join(key)
+	if !leader {
+		return
+	}
+	d := rt.timeout
+	if d <= 0 {
+		d = cacheRevalidateTimeout
+	}
+	// 不随原请求取消，也不共享原请求的状态
+	ctx, cancel := context.WithTimeout(context.WithValue(context.WithoutCancel(r.Context()), ctxKeyState, &reqState{}), d)
+	r = r.Clone(ctx)
+	go func() {
+		defer c.flight.done(key, fc)
+		defer cancel()
+		defer func() {
+			if e := recover(); e != nil {
+				LoggerFrom(r.Context()).Error("[cache] revalidate:", e)
+			}
+		}()
+		var ce *cacheEntry
+		if !runWithTimeout(&discardWriter{h: http.Header{}}, r, rt.Path, func(w http.ResponseWriter) {
+			ce, _, _, _ = c.fill(w, r, key, run)
+		}) {
+			fc.e = ce
+		}
+	}()
+}
+
+func (c *routeCache) setHeaders(w http.ResponseWriter, private bool) {
+	h := w.Header()
+	cc := "public"
+	if private {
+		cc = "private"
+	}
+	cc += ", max-age=" + strconv.Itoa(int(c.o.TTL.Seconds()))
+	if c.o.StaleTTL > 0 {
+		cc += ", stale-while-revalidate=" + strconv.Itoa(int(c.o.StaleTTL.Seconds()))
+	}
+	h.Set("Cache-Control", cc)
+	for _, k := range c.o.KeyHeaders {
+		h.Add("Vary", k)
+	}
+}
+
+func (g *flightGroup) join(key string) (*flightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.m == nil {
+		g.m = map[string]*flightCall{}
+	}
+	if fc, ok := g.m[key]; ok {
+		return fc, false
+	}
+	fc := &flightCall{}
+	fc.wg.Add(1)
+	g.m[key] = fc
+	return fc, true
+}
+
+func (g *flightGroup) done(key string, fc *flightCall) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+	fc.wg.Done()
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.h
+}
+
+func (d *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (d *discardWriter) WriteHeader(int) {}
@@ -0,0 +1,276 @@
+package cc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/jwt"
+)
+
+func TestCacheKey(t *testing.T) {
+	req := func(target string, hdr ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(hdr); i += 2 {
+			r.Header.Set(hdr[i], hdr[i+1])
+		}
+		return r
+	}
+	all := &routeCache{o: CacheOptions{Store: DefaultCacheStore}}
+	page := &routeCache{o: CacheOptions{Store: DefaultCacheStore, KeyQuery: []string{"page"}, KeyHeaders: []string{"Accept-Language"}}}
+	none := &routeCache{o: CacheOptions{Store: DefaultCacheStore, KeyQuery: []string{}}}
+	k := func(c *routeCache, r *http.Request) string { return c.key(r, "/t_key", "") }
+
+	if k(all, req("/x?a=1&b=2&b=1")) != k(all, req("/x?b=1&a=1&b=2")) {
+		t.Error("query order changed key")
+	}
+	if k(all, req("/x?a=1")) == k(all, req("/x?a=2")) || k(all, req("/x?a=1")) == k(all, req("/x?a=1&c=")) {
+		t.Error("query ignored")
+	}
+	if k(all, req("/x")) == k(all, req("/y")) {
+		t.Error("path ignored")
+	}
+	if k(page, req("/x?page=1&t=1")) != k(page, req("/x?page=1&t=2")) || k(page, req("/x?page=1")) == k(page, req("/x?page=2")) {
+		t.Error("KeyQuery not applied")
+	}
+	if k(page, req("/x", "Accept-Language", "en")) == k(page, req("/x", "Accept-Language", "zh")) {
+		t.Error("KeyHeaders ignored")
+	}
+	if k(none, req("/x?a=1")) != k(none, req("/x?a=2")) {
+		t.Error("empty KeyQuery should ignore query")
+	}
+	if all.key(req("/x"), "/t_key", "u:a") == all.key(req("/x"), "/t_key", "u:b") || all.key(req("/x"), "/t_key", "u:a") == k(all, req("/x")) {
+		t.Error("per user key")
+	}
+
+	// 不修改调用方的切片
+	hs := []string{"x-b", "accept-language"}
+	(&Route{}).Cache(CacheOptions{KeyHeaders: hs})
+	if hs[0] != "x-b" || hs[1] != "accept-language" {
+		t.Error("KeyHeaders modified:", hs)
+	}
+}
+
+// PerUser 按 session 区分同一 IP 的用户；无法确定调用方时不使用缓存
+func TestRouteCachePerUserSession(t *testing.T) {
+	var calls atomic.Int64
+	a := ActionGroup{Path: "/t_cache_sess"}.Use(Sessions())
+	a.POST("/login", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		s := ap.Session()
+		s.Rotate()
+		_ = s.Set("name", ap.R.URL.Query().Get("name"))
+		return HerOk()
+	})
+	a.GET("/me", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		calls.Add(1)
+		return HerOkWithString(ap.Session().GetString("name"))
+	}).Cache(CacheOptions{Store: DefaultCacheStore, PerUser: true})
+
+	login := func(name string) string {
+		c, _ := sessionDo(t, "/t_cache_sess/login?name="+name, "")
+		if c == nil {
+			t.Fatal("no session cookie")
+		}
+		return c.Value
+	}
+	me := func(cookie string) (string, string) {
+		r := httptest.NewRequest(http.MethodGet, "/t_cache_sess/me", nil)
+		r.RemoteAddr = "10.0.0.1:1"
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "cc_session", Value: cookie})
+		}
+		w, her := serve(r)
+		return w.Header().Get(HeaderXCache), her.Data
+	}
+	alice, bob := login("alice"), login("bob")
+	for _, c := range []struct{ cookie, want, xc string }{
+		{alice, "alice", "MISS"},
+		{bob, "bob", "MISS"},
+		{alice, "alice", "HIT"},
+		{bob, "bob", "HIT"},
+	} {
+		if xc, d := me(c.cookie); xc != c.xc || d != c.want {
+			t.Errorf("%s: %s %s", c.want, xc, d)
+		}
+	}
+	n := calls.Load()
+	for i := 0; i < 2; i++ {
+		if xc, d := me(""); xc != "" || d != "" {
+			t.Errorf("anonymous: %s %q", xc, d)
+		}
+		if xc, _ := me(alice + "x"); xc != "" {
+			t.Errorf("tampered cookie: %s", xc)
+		}
+	}
+	if calls.Load() != n+4 {
+		t.Errorf("anonymous requests should bypass the cache: %d", calls.Load()-n)
+	}
+}
+
+// 后台刷新超时后释放 key，之后的刷新照常进行
+func TestRouteCacheRevalidateTimeout(t *testing.T) {
+	var calls atomic.Int64
+	hang := make(chan struct{})
+	defer close(hang)
+	ActionGroup{Path: "/t_cache_hang"}.GET("/x", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		n := calls.Add(1)
+		if n == 2 {
+			// 忽略 context 的 handler
+			<-hang
+		}
+		return HerOkWithString(strconv.FormatInt(n, 10))
+	}).Cache(CacheOptions{Store: DefaultCacheStore, TTL: 10 * time.Millisecond, StaleTTL: time.Minute}).Timeout(30 * time.Millisecond)
+
+	get := func() string {
+		w, _ := serve(httptest.NewRequest(http.MethodGet, "/t_cache_hang/x", nil))
+		return w.Header().Get(HeaderXCache)
+	}
+	get()
+	time.Sleep(20 * time.Millisecond)
+	if xc := get(); xc != "STALE" {
+		t.Fatal(xc)
+	}
+	// 第二次调用挂起，超时后应能再次刷新
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 3 && time.Now().Before(deadline) {
+		get()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if calls.Load() < 3 {
+		t.Fatal("revalidation blocked by a hung handler")
+	}
+}
+
+func TestRouteCache(t *testing.T) {
+	var calls atomic.Int64
+	a := ActionGroup{Path: "/t_cache"}
+	// 大整数与 key 顺序在 HIT 时应保持不变
+	a.GET("/data", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		n := calls.Add(1)
+		return HerOkWithData(map[string]interface{}{"z": int64(1)<<62 + 1, "a": n, "s": "<&>"})
+	}).Cache(CacheOptions{Store: DefaultCacheStore, TTL: time.Minute})
+
+	w1, _ := serve(httptest.NewRequest(http.MethodGet, "/t_cache/data", nil))
+	w2, _ := serve(httptest.NewRequest(http.MethodGet, "/t_cache/data", nil))
+	if w1.Header().Get(HeaderXCache) != "MISS" || w2.Header().Get(HeaderXCache) != "HIT" || calls.Load() != 1 {
+		t.Fatalf("x-cache %q %q, calls %d", w1.Header().Get(HeaderXCache), w2.Header().Get(HeaderXCache), calls.Load())
+	}
+	if w1.Body.String() != w2.Body.String() || w1.Header().Get("ETag") != w2.Header().Get("ETag") || w1.Header().Get("ETag") == "" {
+		t.Errorf("HIT differs from MISS:\n%s %s\n%s %s", w1.Body, w1.Header().Get("ETag"), w2.Body, w2.Header().Get("ETag"))
+	}
+	if cc := w2.Header().Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Errorf("Cache-Control %q", cc)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/t_cache/data", nil)
+	r.Header.Set("If-None-Match", w1.Header().Get("ETag"))
+	if w, _ := serve(r); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match on HIT: %d", w.Code)
+	}
+
+	if _, e := PurgeCache(context.Background(), "/t_cache/nope"); e == nil {
+		t.Error("purging unknown route succeeded")
+	}
+	if n, e := PurgeCache(context.Background(), "/t_cache/data"); n != 1 || e != nil {
+		t.Fatalf("purge: %d %v", n, e)
+	}
+	if w, _ := serve(httptest.NewRequest(http.MethodGet, "/t_cache/data", nil)); w.Header().Get(HeaderXCache) != "MISS" || calls.Load() != 2 {
+		t.Errorf("after purge: %q calls %d", w.Header().Get(HeaderXCache), calls.Load())
+	}
+}
+
+// 并发的未命中只执行一次 handler
+func TestRouteCacheSingleflight(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	ActionGroup{Path: "/t_cache_sf"}.GET("/x", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		calls.Add(1)
+		<-release
+		return HerOkWithString("v")
+	}).Cache(CacheOptions{Store: DefaultCacheStore})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 8)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, _ := serve(httptest.NewRequest(http.MethodGet, "/t_cache_sf/x", nil))
+			bodies[i] = w.Body.String()
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("handler ran %d times", calls.Load())
+	}
+	for _, b := range bodies {
+		if b != bodies[0] {
+			t.Errorf("bodies differ: %q %q", b, bodies[0])
+		}
+	}
+}
+
+func TestRouteCacheStale(t *testing.T) {
+	var calls atomic.Int64
+	ActionGroup{Path: "/t_cache_stale"}.GET("/x", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		return HerOkWithString(strconv.FormatInt(calls.Add(1), 10))
+	}).Cache(CacheOptions{Store: DefaultCacheStore, TTL: 30 * time.Millisecond, StaleTTL: time.Minute})
+
+	get := func() (string, string) {
+		w, her := serve(httptest.NewRequest(http.MethodGet, "/t_cache_stale/x", nil))
+		return w.Header().Get(HeaderXCache), her.Data
+	}
+	if xc, d := get(); xc != "MISS" || d != "1" {
+		t.Fatalf("first: %s %s", xc, d)
+	}
+	time.Sleep(50 * time.Millisecond)
+	// 返回旧值并在后台刷新
+	if xc, d := get(); xc != "STALE" || d != "1" {
+		t.Fatalf("stale: %s %s", xc, d)
+	}
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if xc, d := get(); xc != "HIT" || d != "2" {
+		t.Errorf("after revalidate: %s %s", xc, d)
+	}
+}
+
+// 需要认证的路由使用 private 并按调用方缓存
+func TestRouteCacheAuth(t *testing.T) {
+	secret := []byte("cache-jwt")
+	v := jwt.NewVerifier(jwt.Options{Keys: jwt.NewHMACKeySet(secret)})
+	a := ActionGroup{Path: "/t_cache_auth"}.Use(JWTAuth(v)).RequireAuth()
+	me := func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOkWithString(ap.Claims().Subject) }
+	a.GET("/me", me).Cache(CacheOptions{Store: DefaultCacheStore})
+	a.GET("/shared", me).Cache(CacheOptions{Store: DefaultCacheStore, Shared: true})
+
+	get := func(path, sub string) (*httptest.ResponseRecorder, HttpErrReturn) {
+		tok, _ := jwt.Sign(jwt.Claims{Subject: sub}, jwt.HS256, "", secret)
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer "+tok)
+		return serve(r)
+	}
+	for _, sub := range []string{"alice", "bob", "alice"} {
+		w, her := get("/t_cache_auth/me", sub)
+		if her.Data != sub || w.Header().Get("Cache-Control") != "private, max-age=60" {
+			t.Errorf("%s: %+v %v", sub, her, w.Header())
+		}
+	}
+	get("/t_cache_auth/shared", "alice")
+	w, her := get("/t_cache_auth/shared", "bob")
+	if her.Data != "alice" || w.Header().Get(HeaderXCache) != "HIT" || w.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Errorf("shared: %+v %v", her, w.Header())
+	}
+	if w, _ := serve(httptest.NewRequest(http.MethodGet, "/t_cache_auth/me", nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: %d", w.Code)
+	}
+}
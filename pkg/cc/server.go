@@ -0,0 +1,61 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/kpango/glg"
+)
+
+var (
+	// 服务器生命周期；Shutdown 等待请求处理完成（或 ctx 到期）后取消，剩余请求的 context 随之取消
+	serverCtx, serverCancel = context.WithCancel(context.Background())
+	// Shutdown 开始时取消，SSE 等长连接据此提前结束，不阻塞关闭
+	shutdownCtx, shutdownStart = context.WithCancel(context.Background())
+	server                     *http.Server
+	serverMu                   sync.Mutex
+)
+
+// 启动服务器
+// 与 http.ListenAndServe 相同，但可由 Shutdown 优雅关闭；关闭后返回 nil
+func ListenAndServe(addr string) error {
+	serverMu.Lock()
+	server = &http.Server{
+		Addr: addr,
+		BaseContext: func(net.Listener) context.Context {
+			return serverCtx
+		},
+	}
+	s := server
+	serverMu.Unlock()
+
+	glg.Info("server listening on " + addr)
+	if e := s.ListenAndServe(); !errors.Is(e, http.ErrServerClosed) {
+		return e
+	}
+	return nil
+}
+
+// 停止接受新连接，等待处理中的请求完成后关闭服务器
+// ctx 到期或请求均已完成后取消所有剩余请求（如 WS）的 context；
+// 长时间运行的处理函数应当监听 ap.Context().Done()，或通过 IsShuttingDown 提前结束
+func Shutdown(ctx context.Context) error {
+	glg.Warn("server shutting down")
+	shutdownStart()
+	defer serverCancel()
+	serverMu.Lock()
+	s := server
+	serverMu.Unlock()
+	if s == nil {
+		return nil
+	}
+	return s.Shutdown(ctx)
+}
+
+// 服务器是否已经开始关闭
+func IsShuttingDown() bool {
+	return shutdownCtx.Err() != nil
+}
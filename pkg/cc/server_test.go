@@ -0,0 +1,154 @@
+package cc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+// 经 http.DefaultServeMux 发出请求
+func serve(r *http.Request) (*httptest.ResponseRecorder, HttpErrReturn) {
+	w := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, r)
+	var her HttpErrReturn
+	_ = json.Unmarshal(w.Body.Bytes(), &her)
+	return w, her
+}
+
+func TestRouteContext(t *testing.T) {
+	a := ActionGroup{Path: "/t_ctx"}
+	a.GET("/scope", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		if RequestID(ap.Context()) == "" || LoggerFrom(ap.Context()) == nil {
+			return HerArgInvalid("scope")
+		}
+		return HerOk()
+	})
+	a.GET("/slow", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		select {
+		case <-ap.Context().Done():
+		case <-time.After(time.Second):
+		}
+		return HerOk()
+	}).Timeout(20 * time.Millisecond)
+
+	w, her := serve(httptest.NewRequest(http.MethodGet, "/t_ctx/scope", nil))
+	if her.ErrCod != err_code.ERR_OK || w.Header().Get(HeaderRequestID) == "" {
+		t.Fatal(w.Code, her, w.Header())
+	}
+	st := time.Now()
+	w, her = serve(httptest.NewRequest(http.MethodGet, "/t_ctx/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || her.ErrCod != err_code.ERR_TIMEOUT || time.Since(st) > 500*time.Millisecond {
+		t.Fatal(w.Code, her, time.Since(st))
+	}
+}
+
+func TestRouteCSP(t *testing.T) {
+	a := ActionGroup{Path: "/t_csp"}.Use(mwu.SecureHeaders(mwu.SecureHeadersOptions{CSP: "default-src 'self'", CSPReportURI: "/r", NoSniff: true}))
+	a.GET("/default", func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOk() })
+	a.GET("/custom", func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOk() }).CSP("img-src *")
+	a.GET("/none", func(ap ActionPackage) (HttpErrReturn, StatusCode) { return HerOk() }).CSP("")
+
+	cases := map[string]string{
+		"/t_csp/default": "default-src 'self'; report-uri /r",
+		"/t_csp/custom":  "img-src *; report-uri /r",
+		"/t_csp/none":    "",
+	}
+	for path, want := range cases {
+		w, _ := serve(httptest.NewRequest(http.MethodGet, path, nil))
+		if got := w.Header().Get(mwu.HeaderCSP); got != want || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s: CSP %q, want %q; %v", path, got, want, w.Header())
+		}
+	}
+}
+
+// Shutdown 不可撤销，因此在子进程中执行
+func TestShutdown(t *testing.T) {
+	if os.Getenv("CC_TEST_SHUTDOWN") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestShutdown$", "-test.v")
+		cmd.Env = append(os.Environ(), "CC_TEST_SHUTDOWN=1")
+		if out, e := cmd.CombinedOutput(); e != nil {
+			t.Fatal(e, "\n", string(out))
+		}
+		return
+	}
+
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	started := make(chan struct{}, 2)
+	a := ActionGroup{Path: "/t_shutdown"}
+	a.GET("/drain", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		started <- struct{}{}
+		time.Sleep(200 * time.Millisecond)
+		if ap.Context().Err() != nil {
+			return HerFromError(ap.Context().Err())
+		}
+		return HerOk()
+	})
+	a.GET("/hang", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		started <- struct{}{}
+		<-ap.Context().Done()
+		return HerOk()
+	})
+
+	served := make(chan error, 1)
+	go func() { served <- ListenAndServe(addr) }()
+	get := func(path string, res chan<- HttpErrReturn) {
+		var (
+			resp *http.Response
+			e    error
+		)
+		for i := 0; i < 50; i++ {
+			if resp, e = http.Get("http://" + addr + path); e == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		var her HttpErrReturn
+		if e == nil {
+			_ = json.NewDecoder(resp.Body).Decode(&her)
+			resp.Body.Close()
+		}
+		res <- her
+	}
+	drain, hang := make(chan HttpErrReturn, 1), make(chan HttpErrReturn, 1)
+	go get("/t_shutdown/drain", drain)
+	go get("/t_shutdown/hang", hang)
+	<-started
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	st := time.Now()
+	e = Shutdown(ctx)
+	if !errors.Is(e, context.DeadlineExceeded) || time.Since(st) < 400*time.Millisecond {
+		t.Fatal("shutdown should wait for the hanging request:", e, time.Since(st))
+	}
+	if e = <-served; e != nil {
+		t.Fatal(e)
+	}
+	if her := <-drain; her.ErrCod != err_code.ERR_OK {
+		t.Fatal("in-flight request should drain:", her)
+	}
+	// ctx 到期后剩余请求被取消
+	if her := <-hang; her.ErrCod != err_code.ERR_UNAVAILABLE {
+		t.Fatal("remaining request should be canceled:", her)
+	}
+	if !IsShuttingDown() {
+		t.Fatal("IsShuttingDown")
+	}
+}
@@ -0,0 +1,377 @@
+package cc
+
+/**
+服务端 session
+
+	mw.Register(cc.Sessions(cc.SessionOptions{Store: kv.NewRedis(config.RedisCfg)}))
+
+	a.POST("/login", func(ap cc.ActionPackage) (cc.HttpErrReturn, cc.StatusCode) {
+		s := ap.Session()
+		s.Rotate() // 登录后更换 session ID，防止会话固定
+		_ = s.Set("uid", uid)
+		return cc.HerOk()
+	})
+
+cookie 中只保存签名（或加密）后的 session ID，数据保存在 Store 中
+Set、Rotate、Destroy 需要写 cookie，应在写出响应之前调用
+*/
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/config"
+	"github.com/cyf-gh/ccgo/pkg/cc/kv"
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+type (
+	SessionOptions struct {
+		// 默认 kv.NewMemory()
+		Store kv.Store
+		// 默认 cc_session
+		CookieName string
+		// 默认取 server.cfg 的 [session] secret，未配置时每次启动随机生成
+		Secret []byte
+		// cookie 中的 session ID 以 AES-GCM 加密，否则只签名
+		Encrypt bool
+		// 无访问超过该时间后失效，默认 30 分钟
+		IdleTimeout time.Duration
+		// 创建（或 Rotate）超过该时间后失效，默认 24 小时
+		AbsoluteTimeout time.Duration
+		Path            string // 默认 /
+		Domain          string
+		SameSite        http.SameSite // 默认 Lax
+	}
+
+	Session struct {
+		mu        sync.Mutex
+		o         *sessionOptions
+		w         http.ResponseWriter
+		r         *http.Request
+		id        string
+		rec       sessionRecord
+		dirty     bool
+		destroyed bool
+	}
+
+	sessionRecord struct {
+		Created int64                      `json:"c"`
+		Seen    int64                      `json:"s"`
+		Data    map[string]json.RawMessage `json:"d"`
+	}
+
+	sessionOptions struct {
+		SessionOptions
+		signKey []byte
+		aead    cipher.AEAD
+	}
+
+	sessionState struct {
+		o *sessionOptions
+		s *Session
+	}
+)
+
+var (
+	ErrSessionDestroyed = errors.New("session destroyed")
+)
+
+// session 中间件，请求结束时保存修改过的 session
+func Sessions(opts ...SessionOptions) middleware.MiddewareFunc {
+	o := newSessionOptions(opts...)
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			st := &sessionState{o: o}
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeySession, st))
+			defer func() {
+				if st.s != nil {
+					if e := st.s.save(); e != nil {
+						LoggerFrom(r.Context()).Error("[session] save:", e)
+					}
+				}
+			}()
+			f(w, r)
+		}
+	}
+}
+
+func newSessionOptions(opts ...SessionOptions) *sessionOptions {
+	var o sessionOptions
+	if len(opts) > 0 {
+		o.SessionOptions = opts[0]
+	}
+	if o.Store == nil {
+		o.Store = kv.NewMemory()
+	}
+	if o.CookieName == "" {
+		o.CookieName = "cc_session"
+	}
+	if len(o.Secret) == 0 && config.SessionSecret != "" {
+		o.Secret = []byte(config.SessionSecret)
+	}
+	if len(o.Secret) == 0 {
+		o.Secret = make([]byte, 32)
+		_, _ = rand.Read(o.Secret)
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = 30 * time.Minute
+	}
+	if o.AbsoluteTimeout <= 0 {
+		o.AbsoluteTimeout = 24 * time.Hour
+	}
+	if o.Path == "" {
+		o.Path = "/"
+	}
+	if o.SameSite == 0 {
+		o.SameSite = http.SameSiteLaxMode
+	}
+	sk := sha256.Sum256(append([]byte("cc-session-sign:"), o.Secret...))
+	o.signKey = sk[:]
+	if o.Encrypt {
+		ek := sha256.Sum256(append([]byte("cc-session-enc:"), o.Secret...))
+		block, _ := aes.NewCipher(ek[:])
+		o.aead, _ = cipher.NewGCM(block)
+	}
+	return &o
+}
+
+// 当前请求的 session，首次调用时从 Store 读取
+// 未启用 Sessions 中间件时返回 nil
+func (R ActionPackage) Session() *Session {
+	st, ok := R.Context().Value(ctxKeySession).(*sessionState)
+	if !ok {
+		return nil
+	}
+	if st.s == nil {
+		st.s = st.o.load(*R.W, R.R)
+	}
+	return st.s
+}
+
+// 请求 cookie 中通过校验的 session ID，不读取 Store
+// 未启用 Sessions 中间件或没有有效 cookie 时为空
+func sessionIDOf(r *http.Request) string {
+	st, ok := r.Context().Value(ctxKeySession).(*sessionState)
+	if !ok {
+		return ""
+	}
+	c, e := r.Cookie(st.o.CookieName)
+	if e != nil {
+		return ""
+	}
+	if id, ok := st.o.decode(c.Value); ok {
+		return id
+	}
+	return ""
+}
+
+func (o *sessionOptions) load(w http.ResponseWriter, r *http.Request) *Session {
+	s := &Session{o: o, w: w, r: r}
+	c, e := r.Cookie(o.CookieName)
+	if e != nil {
+		return s
+	}
+	id, ok := o.decode(c.Value)
+	if !ok {
+		return s
+	}
+	b, e := o.Store.Get(r.Context(), o.key(id))
+	if e != nil {
+		if !errors.Is(e, kv.ErrNotFound) {
+			LoggerFrom(r.Context()).Error("[session] load:", e)
+		}
+		return s
+	}
+	var rec sessionRecord
+	if json.Unmarshal(b, &rec) != nil {
+		return s
+	}
+	now := time.Now()
+	if now.Sub(time.Unix(rec.Created, 0)) > o.AbsoluteTimeout || now.Sub(time.Unix(rec.Seen, 0)) > o.IdleTimeout {
+		_ = o.Store.Delete(r.Context(), o.key(id))
+		return s
+	}
+	s.id, s.rec = id, rec
+	return s
+}
+
+func (o *sessionOptions) key(id string) string {
+	return "session:" + id
+}
+
+func (o *sessionOptions) encode(id string) string {
+	if o.aead != nil {
+		nonce := make([]byte, o.aead.NonceSize())
+		_, _ = rand.Read(nonce)
+		return base64.RawURLEncoding.EncodeToString(o.aead.Seal(nonce, nonce, []byte(id), nil))
+	}
+	m := hmac.New(sha256.New, o.signKey)
+	m.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func (o *sessionOptions) decode(v string) (string, bool) {
+	if o.aead != nil {
+		b, e := base64.RawURLEncoding.DecodeString(v)
+		if e != nil || len(b) < o.aead.NonceSize() {
+			return "", false
+		}
+		n := o.aead.NonceSize()
+		id, e := o.aead.Open(nil, b[:n], b[n:], nil)
+		return string(id), e == nil
+	}
+	id, _, ok := strings.Cut(v, ".")
+	return id, ok && hmac.Equal([]byte(v), []byte(o.encode(id)))
+}
+
+// session ID，尚未创建时为空
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// 将 key 对应的值解析到 v 中，不存在时返回 false
+func (s *Session) Get(key string, v interface{}) bool {
+	s.mu.Lock()
+	raw, ok := s.rec.Data[key]
+	s.mu.Unlock()
+	return ok && json.Unmarshal(raw, v) == nil
+}
+
+func (s *Session) GetString(key string) string {
+	var v string
+	s.Get(key, &v)
+	return v
+}
+
+// 设置值，v 需可序列化为 json
+// 首次设置时创建 session 并下发 cookie
+func (s *Session) Set(key string, v interface{}) error {
+	jn, e := json.Marshal(v)
+	if e != nil {
+		return e
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.destroyed {
+		return ErrSessionDestroyed
+	}
+	if s.id == "" {
+		s.create()
+	}
+	if s.rec.Data == nil {
+		s.rec.Data = map[string]json.RawMessage{}
+	}
+	s.rec.Data[key] = jn
+	s.dirty = true
+	return nil
+}
+
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rec.Data[key]; ok {
+		delete(s.rec.Data, key)
+		s.dirty = true
+	}
+}
+
+// 清空所有值，保留 session ID
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.rec.Data) > 0 {
+		s.rec.Data = nil
+		s.dirty = true
+	}
+}
+
+// 更换 session ID 并保留数据，应在登录、提权后调用
+// 绝对过期时间从此刻重新计算
+func (s *Session) Rotate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.id != "" {
+		_ = s.o.Store.Delete(s.r.Context(), s.o.key(s.id))
+	}
+	s.destroyed = false
+	s.create()
+	s.dirty = true
+}
+
+// 删除 session 并清除 cookie，用于登出
+func (s *Session) Destroy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroyed = true
+	s.rec = sessionRecord{}
+	http.SetCookie(s.w, s.cookie("", -1))
+	if s.id == "" {
+		return nil
+	}
+	id := s.id
+	s.id = ""
+	return s.o.Store.Delete(s.r.Context(), s.o.key(id))
+}
+
+func (s *Session) create() {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	s.id = hex.EncodeToString(b)
+	s.rec.Created = time.Now().Unix()
+	http.SetCookie(s.w, s.cookie(s.o.encode(s.id), int(s.o.AbsoluteTimeout.Seconds())))
+}
+
+func (s *Session) cookie(v string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.o.CookieName,
+		Value:    v,
+		Path:     s.o.Path,
+		Domain:   s.o.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   mwu.IsHTTPS(s.r) || s.o.SameSite == http.SameSiteNoneMode,
+		SameSite: s.o.SameSite,
+	}
+}
+
+// 修改过，或距上次访问超过一定时间（用于延长空闲过期）时写入 Store
+func (s *Session) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.destroyed || s.id == "" {
+		return nil
+	}
+	now := time.Now()
+	touch := min(s.o.IdleTimeout/10, time.Minute)
+	if !s.dirty && now.Sub(time.Unix(s.rec.Seen, 0)) < touch {
+		return nil
+	}
+	s.rec.Seen = now.Unix()
+	ttl := min(s.o.IdleTimeout, time.Unix(s.rec.Created, 0).Add(s.o.AbsoluteTimeout).Sub(now))
+	if ttl <= 0 {
+		return s.o.Store.Delete(context.WithoutCancel(s.r.Context()), s.o.key(s.id))
+	}
+	b, e := json.Marshal(s.rec)
+	if e != nil {
+		return e
+	}
+	s.dirty = false
+	// 客户端断开时仍然保存
+	return s.o.Store.Set(context.WithoutCancel(s.r.Context()), s.o.key(s.id), b, ttl)
+}
@@ -0,0 +1,152 @@
+package cc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/kv"
+)
+
+// 带 cookie 发出请求，返回响应中的 cc_session（未下发时为 nil）
+func sessionDo(t *testing.T, path, cookie string) (*http.Cookie, HttpErrReturn) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, path, nil)
+	if cookie != "" {
+		r.AddCookie(&http.Cookie{Name: "cc_session", Value: cookie})
+	}
+	w, her := serve(r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: %d %+v", path, w.Code, her)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "cc_session" {
+			return c, her
+		}
+	}
+	return nil, her
+}
+
+func sessionRoutes(prefix string, o SessionOptions) {
+	a := ActionGroup{Path: prefix}.Use(Sessions(o))
+	a.POST("/login", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		s := ap.Session()
+		s.Rotate()
+		_ = s.Set("uid", 42)
+		return HerOkWithString(s.ID())
+	})
+	a.POST("/me", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		var uid int
+		ap.Session().Get("uid", &uid)
+		return HerOkWithData(uid)
+	})
+	a.POST("/logout", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		if e := ap.Session().Destroy(); e != nil {
+			return HerFromError(e)
+		}
+		return HerOk()
+	})
+}
+
+func TestSession(t *testing.T) {
+	for _, enc := range []bool{false, true} {
+		prefix := "/t_sess"
+		if enc {
+			prefix += "_enc"
+		}
+		store := kv.NewMemory()
+		sessionRoutes(prefix, SessionOptions{Store: store, Secret: []byte("s"), Encrypt: enc})
+
+		if c, her := sessionDo(t, prefix+"/me", ""); c != nil || her.Data != "0" {
+			t.Fatalf("anonymous request created a session: %v %+v", c, her)
+		}
+		c, her := sessionDo(t, prefix+"/login", "")
+		id := her.Data
+		if c == nil || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || id == "" {
+			t.Fatalf("login cookie: %+v %+v", c, her)
+		}
+		if strings.Contains(c.Value, id) == enc {
+			t.Errorf("encrypt=%v: cookie %q, id %q", enc, c.Value, id)
+		}
+		if _, her = sessionDo(t, prefix+"/me", c.Value); her.ErrCod != err_code.ERR_OK || her.Data != "42" {
+			t.Fatalf("session not loaded: %+v", her)
+		}
+		// 篡改的 cookie 视为无 session
+		if _, her = sessionDo(t, prefix+"/me", c.Value[:len(c.Value)-2]+"xx"); her.Data != "0" {
+			t.Errorf("tampered cookie accepted: %+v", her)
+		}
+
+		// Rotate 后旧 ID 失效，数据保留
+		c2, her := sessionDo(t, prefix+"/login", c.Value)
+		if c2 == nil || her.Data == id {
+			t.Fatalf("rotate: %+v %+v", c2, her)
+		}
+		if _, e := store.Get(context.Background(), "session:"+id); e != kv.ErrNotFound {
+			t.Errorf("old session still stored: %v", e)
+		}
+		if _, her = sessionDo(t, prefix+"/me", c.Value); her.Data != "0" {
+			t.Errorf("old cookie still valid: %+v", her)
+		}
+
+		dc, _ := sessionDo(t, prefix+"/logout", c2.Value)
+		if dc == nil || dc.MaxAge >= 0 {
+			t.Errorf("logout cookie: %+v", dc)
+		}
+		if _, her = sessionDo(t, prefix+"/me", c2.Value); her.Data != "0" {
+			t.Errorf("destroyed session still valid: %+v", her)
+		}
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	store := kv.NewMemory()
+	sessionRoutes("/t_sess_exp", SessionOptions{Store: store, Secret: []byte("s"), IdleTimeout: time.Hour, AbsoluteTimeout: 2 * time.Hour})
+	c, her := sessionDo(t, "/t_sess_exp/login", "")
+	key := "session:" + her.Data
+
+	// 将记录改写为指定的创建与访问时间
+	age := func(created, seen time.Duration) {
+		b, e := store.Get(context.Background(), key)
+		if e != nil {
+			t.Fatal(e)
+		}
+		var rec sessionRecord
+		_ = json.Unmarshal(b, &rec)
+		now := time.Now()
+		rec.Created, rec.Seen = now.Add(-created).Unix(), now.Add(-seen).Unix()
+		b, _ = json.Marshal(rec)
+		_ = store.Set(context.Background(), key, b, 0)
+	}
+
+	age(90*time.Minute, 30*time.Minute)
+	if _, her = sessionDo(t, "/t_sess_exp/me", c.Value); her.Data != "42" {
+		t.Fatalf("session expired too early: %+v", her)
+	}
+	// 访问后更新了 Seen
+	b, _ := store.Get(context.Background(), key)
+	var rec sessionRecord
+	_ = json.Unmarshal(b, &rec)
+	if time.Since(time.Unix(rec.Seen, 0)) > time.Minute {
+		t.Errorf("idle timer not refreshed: %v", time.Unix(rec.Seen, 0))
+	}
+
+	age(time.Minute, 61*time.Minute)
+	if _, her = sessionDo(t, "/t_sess_exp/me", c.Value); her.Data != "0" {
+		t.Errorf("idle session accepted: %+v", her)
+	}
+	if _, e := store.Get(context.Background(), key); e != kv.ErrNotFound {
+		t.Errorf("idle session not deleted: %v", e)
+	}
+
+	c, her = sessionDo(t, "/t_sess_exp/login", "")
+	key = "session:" + her.Data
+	age(121*time.Minute, 0)
+	if _, her = sessionDo(t, "/t_sess_exp/me", c.Value); her.Data != "0" {
+		t.Errorf("session past absolute timeout accepted: %+v", her)
+	}
+}
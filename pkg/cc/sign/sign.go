@@ -0,0 +1,213 @@
+// 服务间调用的请求签名
+//
+// 规范请求（canonical request）为以下各行以 \n 连接：
+//
+//	METHOD
+//	转义后的路径
+//	按键、值排序后的 query
+//	host
+//	时间戳（unix 秒）
+//	nonce
+//	body 的 sha256（hex）
+//
+// 签名为 HMAC-SHA256(secret, 规范请求) 的 base64（RawURL），与 key ID、时间戳、nonce 一起放入请求头
+package sign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/kv"
+)
+
+const (
+	HeaderKeyID     = "X-CC-Key"
+	HeaderTimestamp = "X-CC-Timestamp"
+	HeaderNonce     = "X-CC-Nonce"
+	HeaderSignature = "X-CC-Signature"
+)
+
+type (
+	Verifier struct {
+		// 按 key ID 取得密钥，不存在时返回 false
+		Secret func(keyID string) ([]byte, bool)
+		// 允许的时钟偏差，默认 5 分钟；nonce 也保存这么久
+		MaxSkew time.Duration
+		// 已使用的 nonce，NewVerifier 默认使用 kv.NewMemory()；多实例部署时应使用共享的存储
+		Nonces kv.Store
+		// 读取 body 的上限，默认 32MB
+		MaxBody int64
+	}
+)
+
+var (
+	ErrMissing   = errors.New("sign: missing signature headers")
+	ErrUnknown   = errors.New("sign: unknown key")
+	ErrSkew      = errors.New("sign: timestamp out of range")
+	ErrSignature = errors.New("sign: invalid signature")
+	ErrReplay    = errors.New("sign: nonce already used")
+	ErrBodyLarge = errors.New("sign: body too large")
+	ErrNoNonces  = errors.New("sign: nonce store not set")
+)
+
+// 为请求签名并设置请求头，body 会被读取后替换为可重复读取的副本
+func Sign(r *http.Request, keyID string, secret []byte) error {
+	body, e := readBody(r, 0)
+	if e != nil {
+		return e
+	}
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	n := hex.EncodeToString(nonce)
+	r.Header.Set(HeaderKeyID, keyID)
+	r.Header.Set(HeaderTimestamp, ts)
+	r.Header.Set(HeaderNonce, n)
+	r.Header.Set(HeaderSignature, signature(secret, Canonical(r, ts, n, body)))
+	return nil
+}
+
+// 使用固定密钥表
+func StaticKeys(keys map[string][]byte) func(string) ([]byte, bool) {
+	return func(id string) ([]byte, bool) {
+		k, ok := keys[id]
+		return k, ok
+	}
+}
+
+func NewVerifier(secret func(keyID string) ([]byte, bool)) *Verifier {
+	return &Verifier{Secret: secret, Nonces: kv.NewMemory()}
+}
+
+// 校验请求，成功时返回 key ID
+// body 会被读取后替换，之后的 handler 仍可读取
+func (v *Verifier) Verify(r *http.Request) (string, error) {
+	id, ts, n, sig := r.Header.Get(HeaderKeyID), r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce), r.Header.Get(HeaderSignature)
+	if id == "" || ts == "" || n == "" || sig == "" {
+		return "", ErrMissing
+	}
+	secret, ok := v.Secret(id)
+	if !ok {
+		return "", ErrUnknown
+	}
+	skew := v.MaxSkew
+	if skew <= 0 {
+		skew = 5 * time.Minute
+	}
+	sec, e := strconv.ParseInt(ts, 10, 64)
+	if e != nil {
+		return "", ErrSkew
+	}
+	if d := time.Since(time.Unix(sec, 0)); d > skew || d < -skew {
+		return "", ErrSkew
+	}
+	limit := v.MaxBody
+	if limit <= 0 {
+		limit = 32 << 20
+	}
+	body, e := readBody(r, limit)
+	if e != nil {
+		return "", e
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, Canonical(r, ts, n, body)))) {
+		return "", ErrSignature
+	}
+	// 签名通过后再记录 nonce，避免伪造的请求占用 nonce
+	if v.Nonces == nil {
+		return "", ErrNoNonces
+	}
+	fresh, e := v.Nonces.SetNX(r.Context(), "sign-nonce:"+id+":"+n, []byte{1}, 2*skew)
+	if e != nil {
+		return "", e
+	}
+	if !fresh {
+		return "", ErrReplay
+	}
+	return id, nil
+}
+
+// 规范请求
+func Canonical(r *http.Request, ts, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	host := r.Host
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+	return strings.Join([]string{
+		strings.ToUpper(r.Method),
+		r.URL.EscapedPath(),
+		canonicalQuery(r),
+		strings.ToLower(host),
+		ts,
+		nonce,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+func canonicalQuery(r *http.Request) string {
+	q := r.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, val := range vs {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(escape(k) + "=" + escape(val))
+		}
+	}
+	return sb.String()
+}
+
+// 与 url.QueryEscape 相同，但空格编码为 %20
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func signature(secret []byte, canonical string) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// 读取 body 并替换为可重复读取的副本，limit <= 0 为不限制
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	rd := io.Reader(r.Body)
+	if limit > 0 {
+		rd = io.LimitReader(r.Body, limit+1)
+	}
+	b, e := io.ReadAll(rd)
+	_ = r.Body.Close()
+	if e != nil {
+		return nil, e
+	}
+	if limit > 0 && int64(len(b)) > limit {
+		return nil, ErrBodyLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return b, nil
+}
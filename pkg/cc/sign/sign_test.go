@@ -0,0 +1,122 @@
+package sign
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanonical(t *testing.T) {
+	r := httptest.NewRequest("post", "http://API.Example.com/a%20b/c?z=2&a=b&a=a&sp=x+y&e=", nil)
+	got := Canonical(r, "100", "n1", []byte("hello"))
+	want := strings.Join([]string{
+		"POST",
+		"/a%20b/c",
+		"a=a&a=b&e=&sp=x%20y&z=2",
+		"api.example.com",
+		"100",
+		"n1",
+		"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}, "\n")
+	if got != want {
+		t.Errorf("canonical:\n%s\nwant:\n%s", got, want)
+	}
+	// query 的顺序不影响签名
+	r2 := httptest.NewRequest("POST", "http://api.example.com/a%20b/c?sp=x%20y&e=&a=a&z=2&a=b", nil)
+	if Canonical(r2, "100", "n1", []byte("hello")) != want {
+		t.Error("query order changed canonical request")
+	}
+}
+
+func signedRequest(t *testing.T, method, target, body string) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if e := Sign(r, "svc", []byte("secret")); e != nil {
+		t.Fatal(e)
+	}
+	// Sign 替换了 body，模拟经网络到达服务端
+	b, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(strings.NewReader(string(b)))
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	v := NewVerifier(StaticKeys(map[string][]byte{"svc": []byte("secret")}))
+
+	r := signedRequest(t, http.MethodPost, "http://h/p?a=1", `{"x":1}`)
+	replay := r.Clone(r.Context())
+	if id, e := v.Verify(r); id != "svc" || e != nil {
+		t.Fatalf("verify: %q %v", id, e)
+	}
+	if b, _ := io.ReadAll(r.Body); string(b) != `{"x":1}` {
+		t.Errorf("body after verify: %q", b)
+	}
+	replay.Body = io.NopCloser(strings.NewReader(`{"x":1}`))
+	if _, e := v.Verify(replay); !errors.Is(e, ErrReplay) {
+		t.Errorf("replay: %v", e)
+	}
+
+	tamper := []struct {
+		name string
+		f    func(r *http.Request)
+		want error
+	}{
+		{"body", func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader(`{"x":2}`)) }, ErrSignature},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "a=2" }, ErrSignature},
+		{"path", func(r *http.Request) { r.URL.Path = "/q" }, ErrSignature},
+		{"method", func(r *http.Request) { r.Method = http.MethodPut }, ErrSignature},
+		{"nonce", func(r *http.Request) { r.Header.Set(HeaderNonce, "other") }, ErrSignature},
+		{"key", func(r *http.Request) { r.Header.Set(HeaderKeyID, "nobody") }, ErrUnknown},
+		{"missing", func(r *http.Request) { r.Header.Del(HeaderSignature) }, ErrMissing},
+		{"timestamp", func(r *http.Request) { r.Header.Set(HeaderTimestamp, "x") }, ErrSkew},
+	}
+	for _, c := range tamper {
+		r := signedRequest(t, http.MethodPost, "http://h/p?a=1", `{"x":1}`)
+		c.f(r)
+		if _, e := v.Verify(r); !errors.Is(e, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, e, c.want)
+		}
+	}
+}
+
+func TestVerifySkew(t *testing.T) {
+	v := NewVerifier(StaticKeys(map[string][]byte{"svc": []byte("secret")}))
+	v.MaxSkew = time.Minute
+	// 以指定的时间戳重新签名
+	at := func(d time.Duration, nonce string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://h/p", nil)
+		ts := strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+		r.Header.Set(HeaderKeyID, "svc")
+		r.Header.Set(HeaderTimestamp, ts)
+		r.Header.Set(HeaderNonce, nonce)
+		r.Header.Set(HeaderSignature, signature([]byte("secret"), Canonical(r, ts, nonce, nil)))
+		return r
+	}
+	for d, want := range map[time.Duration]error{
+		0:                 nil,
+		-50 * time.Second: nil,
+		50 * time.Second:  nil,
+		-2 * time.Minute:  ErrSkew,
+		2 * time.Minute:   ErrSkew,
+	} {
+		if _, e := v.Verify(at(d, "n"+d.String())); !errors.Is(e, want) {
+			t.Errorf("skew %v: got %v, want %v", d, e, want)
+		}
+	}
+}
+
+func TestVerifyBodyLimit(t *testing.T) {
+	v := NewVerifier(StaticKeys(map[string][]byte{"svc": []byte("secret")}))
+	v.MaxBody = 4
+	if _, e := v.Verify(signedRequest(t, http.MethodPost, "http://h/p", "12345")); !errors.Is(e, ErrBodyLarge) {
+		t.Errorf("large body: %v", e)
+	}
+	if _, e := v.Verify(signedRequest(t, http.MethodPost, "http://h/p", "1234")); e != nil {
+		t.Errorf("body at limit: %v", e)
+	}
+}
@@ -0,0 +1,59 @@
+package cc
+
+/**
+服务间请求签名
+
+服务端：
+
+	v := sign.NewVerifier(sign.StaticKeys(map[string][]byte{"billing": secret}))
+	cc.AddActionGroup("/internal", func(a cc.ActionGroup) error {
+		a = a.Use(cc.VerifySignature(v))
+		...
+	})
+
+客户端：
+
+	e := cc.PostJ(u, body, &resp, cc.WithSignature("billing", secret))
+*/
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+	"github.com/cyf-gh/ccgo/pkg/cc/sign"
+)
+
+// 校验请求签名，未签名或校验失败返回 401 与 ERR_NO_AUTH
+// 应通过 ActionGroup.Use 用于只接受服务间调用的路由组
+func VerifySignature(v *sign.Verifier) middleware.MiddewareFunc {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			id, e := v.Verify(r)
+			if e != nil {
+				LoggerFrom(r.Context()).Warn("[sign]", r.URL.Path, e)
+				her, status := HerFromError(ErrNoAuth(e.Error()))
+				if errors.Is(e, sign.ErrBodyLarge) {
+					her, status = HerBodyTooLarge()
+				}
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+				return
+			}
+			f(w, r.WithContext(context.WithValue(r.Context(), ctxKeySigner, id)))
+		}
+	}
+}
+
+// 签名请求的 key ID，未经 VerifySignature 校验时为空
+func (R ActionPackage) SignerKeyID() string {
+	id, _ := R.Context().Value(ctxKeySigner).(string)
+	return id
+}
+
+// 为请求签名
+func WithSignature(keyID string, secret []byte) RequestOption {
+	return func(r *http.Request) error {
+		return sign.Sign(r, keyID, secret)
+	}
+}
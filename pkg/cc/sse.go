@@ -0,0 +1,221 @@
+package cc
+
+/**
+Server-Sent Events
+
+	a.SSE("/progress", func(ap cc.ActionPackage, es *cc.EventStream) error {
+		for p := range progress(es.LastEventID()) {
+			if e := es.Send("progress", p.Id, p.Json()); e != nil {
+				return e // 客户端断开
+			}
+		}
+		return nil
+	})
+*/
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mwh "github.com/cyf-gh/ccgo/pkg/cc/middleware/helper"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+
+	"github.com/kpango/glg"
+)
+
+type (
+	ActionFuncSSE func(ActionPackage, *EventStream) error
+
+	// 事件流
+	// 所有方法可并发调用
+	EventStream struct {
+		mu          sync.Mutex
+		w           http.ResponseWriter
+		rc          *http.ResponseController
+		ctx         context.Context
+		cancel      func()
+		lastEventID string
+		closed      bool
+	}
+)
+
+var (
+	// 心跳间隔，用于保持连接并及时发现断开的客户端
+	SSEHeartbeat    = 15 * time.Second
+	ErrStreamClosed = errors.New("event stream closed")
+)
+
+// 添加一个 Server-Sent Events 请求
+// handler 返回后连接关闭
+func (a ActionGroup) SSE(path string, handler ActionFuncSSE) *Route {
+	checkPathWarning(path)
+	rt := a.route(mwu.GET, path)
+	if a.IsDeprecated(path) {
+		return rt
+	}
+	glg.Log("[action] SSE: ", a.Path+path)
+	http.HandleFunc(a.Path+path, mwh.WrapGet(
+		func(w http.ResponseWriter, r *http.Request) {
+			if her, status, ok := rt.call(w, r, func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+				es := newEventStream(ap)
+				stop := es.heartbeat(SSEHeartbeat)
+				defer es.close(stop)
+				if e := handler(ap, es); e != nil && !errors.Is(e, ErrStreamClosed) {
+					LoggerFrom(ap.Context()).Error("[SSE] ", a.Path+path, ": ", e)
+				}
+				return HerOk()
+			}); ok {
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+			}
+		}, a.mws...))
+	return rt
+}
+
+func newEventStream(ap ActionPackage) *EventStream {
+	w := *ap.W
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// 关闭 nginx 缓冲
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	stateFrom(ap.Context()).written = true
+
+	// 服务器开始关闭时即结束，不等待 Shutdown 超时
+	ctx, cancel := context.WithCancel(ap.Context())
+	stop := context.AfterFunc(shutdownCtx, cancel)
+	es := &EventStream{
+		w:   w,
+		rc:  http.NewResponseController(w),
+		ctx: ctx,
+		cancel: func() {
+			stop()
+			cancel()
+		},
+		lastEventID: ap.R.Header.Get("Last-Event-ID"),
+	}
+	if es.lastEventID == "" {
+		// EventSource 无法自定义请求头时可通过 query 传递
+		es.lastEventID = ap.R.URL.Query().Get("lastEventId")
+	}
+	_ = es.flush()
+	return es
+}
+
+// 客户端断线重连时携带的最后一个事件 ID，用于断点续传
+func (es *EventStream) LastEventID() string {
+	return es.lastEventID
+}
+
+// 客户端断开、路由超时或服务器开始关闭时关闭
+func (es *EventStream) Done() <-chan struct{} {
+	return es.ctx.Done()
+}
+
+// 发送一个事件，event 与 id 可为空
+// 多行 data 会被拆分为多个 data 字段
+func (es *EventStream) Send(event, id, data string) error {
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: " + oneLine(id) + "\n")
+	}
+	if event != "" {
+		sb.WriteString("event: " + oneLine(event) + "\n")
+	}
+	for _, l := range strings.Split(data, "\n") {
+		sb.WriteString("data: " + strings.TrimSuffix(l, "\r") + "\n")
+	}
+	sb.WriteString("\n")
+	return es.write(sb.String())
+}
+
+// 发送 json 数据
+func (es *EventStream) SendJson(event, id string, v interface{}) error {
+	her, _ := HerOkWithData(v)
+	return es.Send(event, id, her.Data)
+}
+
+// 建议客户端的重连间隔
+func (es *EventStream) Retry(d time.Duration) error {
+	return es.write("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+}
+
+// 发送注释，客户端会忽略
+func (es *EventStream) Comment(c string) error {
+	return es.write(": " + oneLine(c) + "\n\n")
+}
+
+func (es *EventStream) write(s string) error {
+	if es.ctx.Err() != nil {
+		return ErrStreamClosed
+	}
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.closed {
+		return ErrStreamClosed
+	}
+	if _, e := es.w.Write([]byte(s)); e != nil {
+		return errors.Join(ErrStreamClosed, e)
+	}
+	if e := es.flush(); e != nil {
+		return errors.Join(ErrStreamClosed, e)
+	}
+	return nil
+}
+
+func (es *EventStream) flush() error {
+	e := es.rc.Flush()
+	if errors.Is(e, http.ErrNotSupported) {
+		return nil
+	}
+	return e
+}
+
+// handler 返回后不再允许写入
+func (es *EventStream) close(stopHeartbeat func()) {
+	es.mu.Lock()
+	es.closed = true
+	es.mu.Unlock()
+	stopHeartbeat()
+	es.cancel()
+}
+
+func (es *EventStream) heartbeat(d time.Duration) (stop func()) {
+	if d <= 0 {
+		return func() {}
+	}
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-es.ctx.Done():
+				return
+			case <-t.C:
+				if es.Comment("ping") != nil {
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func oneLine(s string) string {
+	return strings.NewReplacer("\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,67 @@
+package cc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	a := ActionGroup{Path: "/t_sse"}
+	a.SSE("/events", func(ap ActionPackage, es *EventStream) error {
+		_ = es.Retry(3 * time.Second)
+		_ = es.Send("progress", es.LastEventID()+"-1", "line1\nline2\r\n")
+		_ = es.Send("", "", "plain")
+		_ = es.Comment("a\nb")
+		return es.SendJson("done", "id\nx", map[string]int{"n": 1})
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/t_sse/events", nil)
+	r.Header.Set("Last-Event-ID", "41")
+	w, _ := serve(r)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatal(w.Code, w.Header())
+	}
+	want := "retry: 3000\n\n" +
+		"id: 41-1\nevent: progress\ndata: line1\ndata: line2\ndata: \n\n" +
+		"data: plain\n\n" +
+		": a b\n\n" +
+		"id: id x\nevent: done\ndata: {\"n\":1}\n\n"
+	if w.Body.String() != want {
+		t.Fatalf("%q", w.Body.String())
+	}
+
+	// EventSource 无法设置请求头时通过 query 传递
+	w, _ = serve(httptest.NewRequest(http.MethodGet, "/t_sse/events?lastEventId=7", nil))
+	if !contains(w.Body.String(), "id: 7-1\n") {
+		t.Fatalf("%q", w.Body.String())
+	}
+}
+
+func TestSSEHeartbeatAndClose(t *testing.T) {
+	old := SSEHeartbeat
+	SSEHeartbeat = 10 * time.Millisecond
+	defer func() { SSEHeartbeat = old }()
+
+	var stream *EventStream
+	a := ActionGroup{Path: "/t_sse_hb"}
+	a.SSE("/events", func(ap ActionPackage, es *EventStream) error {
+		stream = es
+		time.Sleep(35 * time.Millisecond)
+		return nil
+	})
+	w, _ := serve(httptest.NewRequest(http.MethodGet, "/t_sse_hb/events", nil))
+	if !contains(w.Body.String(), ": ping\n\n") {
+		t.Fatalf("%q", w.Body.String())
+	}
+	// handler 返回后不再允许写入
+	if e := stream.Send("", "", "late"); e != ErrStreamClosed {
+		t.Fatal(e)
+	}
+	select {
+	case <-stream.Done():
+	default:
+		t.Fatal("stream should be done")
+	}
+}
@@ -0,0 +1,223 @@
+package cc
+
+/**
+文件与媒体服务
+
+	a.STATIC("/music", "./data/music")                          // GET /foo/music/a/b.flac
+	a.STATIC("/pub", "./public", cc.StaticOptions{Listing: true}) // 允许列出目录
+
+	a.GET_CONTENT("/song", func(ap cc.ActionPackage) (cc.HttpErrReturn, cc.StatusCode) {
+		return ap.ServeFile(songPath(ap.GetFormValue("id")))
+	})
+
+Content-Type 取自 ContentType 表（可用 AddContentType 扩展），支持 Range 请求（音频拖动）、
+ETag/Last-Modified 条件请求
+*/
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mwh "github.com/cyf-gh/ccgo/pkg/cc/middleware/helper"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+
+	"github.com/kpango/glg"
+)
+
+type (
+	StaticOptions struct {
+		Listing       bool          // 允许列出目录，默认关闭
+		Index         string        // 目录的默认文件，默认 index.html
+		AllowDotFiles bool          // 允许访问 . 开头的文件，默认关闭
+		MaxAge        time.Duration // Cache-Control max-age，0 则不设置
+	}
+)
+
+var (
+	contentTypeMu sync.RWMutex
+)
+
+// 扩展 ContentType 表，ext 不含 .
+func AddContentType(ext, mimeType string) {
+	contentTypeMu.Lock()
+	ContentType[strings.ToLower(strings.TrimPrefix(ext, "."))] = mimeType
+	contentTypeMu.Unlock()
+}
+
+// 按扩展名取得 MIME，优先使用 ContentType 表
+// 表中以逗号分隔的多个值取第一个
+func ContentTypeOf(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	contentTypeMu.RLock()
+	t, ok := ContentType[ext]
+	contentTypeMu.RUnlock()
+	if ok {
+		return strings.TrimSpace(strings.Split(t, ",")[0])
+	}
+	return mime.TypeByExtension("." + ext)
+}
+
+// 返回文件内容，支持 Range 与条件请求
+// 成功时响应已写出，返回值会被忽略；失败时返回对应的 HER
+func (R ActionPackage) ServeFile(name string) (HttpErrReturn, StatusCode) {
+	f, e := os.Open(name)
+	if e != nil {
+		return herFromFileError(e)
+	}
+	defer f.Close()
+	fi, e := f.Stat()
+	if e != nil {
+		return herFromFileError(e)
+	}
+	if fi.IsDir() {
+		return HerFromError(ErrNotFound("file not found"))
+	}
+	R.serveContent(f, fi, StaticOptions{})
+	return HerOk()
+}
+
+func (R ActionPackage) serveContent(f *os.File, fi fs.FileInfo, o StaticOptions) {
+	h := (*R.W).Header()
+	if ct := ContentTypeOf(fi.Name()); ct != "" {
+		h.Set("Content-Type", ct)
+	}
+	h.Set("ETag", fmt.Sprintf("\"%x-%x\"", fi.ModTime().UnixNano(), fi.Size()))
+	if o.MaxAge > 0 {
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(o.MaxAge.Seconds())))
+	}
+	stateFrom(R.Context()).written = true
+	// 路由设置了超时时不缓存文件内容
+	unbuffer(*R.W)
+	http.ServeContent(*R.W, R.R, fi.Name(), fi.ModTime(), f)
+}
+
+func herFromFileError(e error) (HttpErrReturn, StatusCode) {
+	if errors.Is(e, fs.ErrNotExist) {
+		return HerFromError(ErrNotFound("file not found"))
+	}
+	if errors.Is(e, fs.ErrPermission) {
+		// 服务器无法读取的文件与调用方的认证无关，按不存在处理以免暴露文件是否存在
+		glg.Warn("[static] ", e)
+		return HerFromError(ErrNotFound("file not found"))
+	}
+	return HerFromError(e)
+}
+
+// 添加一个静态文件目录
+// 请求路径为 a.Path + prefix + "/文件相对 dir 的路径"；无法访问 dir 以外的文件
+func (a ActionGroup) STATIC(prefix, dir string, opts ...StaticOptions) *Route {
+	checkPathWarning(prefix)
+	var o StaticOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Index == "" {
+		o.Index = "index.html"
+	}
+	rt := a.route(mwu.GET, prefix+"/")
+	if a.IsDeprecated(prefix + "/") {
+		return rt
+	}
+	root, e := os.OpenRoot(dir)
+	if e != nil {
+		glg.Error("[action] STATIC: ", a.Path+prefix, " cannot open ", dir, ": ", e)
+		return rt
+	}
+	glg.Log("[action] STATIC: ", a.Path+prefix+"/", " -> ", dir)
+	base := a.Path + prefix
+	http.HandleFunc(base+"/", mwh.WrapGet(
+		func(w http.ResponseWriter, r *http.Request) {
+			if her, status, ok := rt.call(w, r, func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+				return ap.serveStatic(root, strings.TrimPrefix(r.URL.Path, base), o)
+			}); ok {
+				HttpReturnHER(&w, &her, status, r.URL.Path)
+			}
+		}, a.mws...))
+	return rt
+}
+
+func (R ActionPackage) serveStatic(root *os.Root, p string, o StaticOptions) (HttpErrReturn, StatusCode) {
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = "."
+	}
+	if !o.AllowDotFiles {
+		for _, seg := range strings.Split(name, "/") {
+			if strings.HasPrefix(seg, ".") && seg != "." {
+				return HerFromError(ErrNotFound("file not found"))
+			}
+		}
+	}
+	f, e := root.Open(name)
+	if e != nil {
+		return herFromFileError(e)
+	}
+	defer f.Close()
+	fi, e := f.Stat()
+	if e != nil {
+		return herFromFileError(e)
+	}
+	if !fi.IsDir() {
+		R.serveContent(f, fi, o)
+		return HerOk()
+	}
+
+	// 目录
+	if idx, e := root.Open(path.Join(name, o.Index)); e == nil {
+		defer idx.Close()
+		if ifi, e := idx.Stat(); e == nil && !ifi.IsDir() {
+			R.serveContent(idx, ifi, o)
+			return HerOk()
+		}
+	}
+	if !o.Listing {
+		return HerFromError(ErrNotFound("file not found"))
+	}
+	if !strings.HasSuffix(R.R.URL.Path, "/") {
+		stateFrom(R.Context()).written = true
+		http.Redirect(*R.W, R.R, R.R.URL.Path+"/", http.StatusMovedPermanently)
+		return HerOk()
+	}
+	return R.listDir(f, o)
+}
+
+func (R ActionPackage) listDir(d *os.File, o StaticOptions) (HttpErrReturn, StatusCode) {
+	es, e := d.ReadDir(-1)
+	if e != nil {
+		return herFromFileError(e)
+	}
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].Name() < es[j].Name()
+	})
+	var sb strings.Builder
+	sb.WriteString("<!doctype html>\n<pre>\n")
+	for _, de := range es {
+		n := de.Name()
+		if !o.AllowDotFiles && strings.HasPrefix(n, ".") {
+			continue
+		}
+		if de.IsDir() {
+			n += "/"
+		}
+		u := url.URL{Path: n}
+		sb.WriteString("<a href=\"" + html.EscapeString(u.String()) + "\">" + html.EscapeString(n) + "</a>\n")
+	}
+	sb.WriteString("</pre>\n")
+
+	stateFrom(R.Context()).written = true
+	(*R.W).Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = (*R.W).Write([]byte(sb.String()))
+	return HerOk()
+}
@@ -0,0 +1,135 @@
+package cc
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatic(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a.flac"), []byte("0123456789"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, ".secret"), []byte("x"), 0644)
+	_ = os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	_ = os.WriteFile(filepath.Join(filepath.Dir(dir), "outside.txt"), []byte("x"), 0644)
+
+	a := ActionGroup{Path: "/t_static"}
+	a.STATIC("/pub", dir)
+	a.STATIC("/list", dir, StaticOptions{Listing: true})
+
+	get := func(url string, h map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		for k, v := range h {
+			r.Header.Set(k, v)
+		}
+		w, _ := serve(r)
+		return w
+	}
+
+	w := get("/t_static/pub/a.flac", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" || w.Header().Get("Content-Type") != "audio/x-flac" {
+		t.Fatal(w.Code, w.Body.String(), w.Header())
+	}
+	etag, lm := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+
+	// 音频拖动
+	w = get("/t_static/pub/a.flac", map[string]string{"Range": "bytes=2-5"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" || w.Header().Get("Content-Range") != "bytes 2-5/10" {
+		t.Fatal(w.Code, w.Body.String(), w.Header())
+	}
+	w = get("/t_static/pub/a.flac", map[string]string{"Range": "bytes=20-"})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatal(w.Code)
+	}
+
+	w = get("/t_static/pub/a.flac", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	w = get("/t_static/pub/a.flac", map[string]string{"If-Modified-Since": lm})
+	if w.Code != http.StatusNotModified {
+		t.Fatal(w.Code)
+	}
+
+	for _, p := range []string{"/t_static/pub/.secret", "/t_static/pub/sub/"} {
+		if w = get(p, nil); w.Code != http.StatusNotFound {
+			t.Fatal(p, w.Code)
+		}
+	}
+	// ServeMux 会重定向含 .. 的路径，直接调用时也无法访问 dir 以外的文件
+	for _, p := range []string{"/t_static/pub/../outside.txt", "/t_static/pub/%2e%2e/outside.txt"} {
+		if w = get(p, nil); w.Code == http.StatusOK {
+			t.Fatal(p, w.Code)
+		}
+	}
+	root, _ := os.OpenRoot(dir)
+	defer root.Close()
+	rw := httptest.NewRecorder()
+	var hw http.ResponseWriter = rw
+	ap := ActionPackage{R: httptest.NewRequest(http.MethodGet, "/t_static/pub/x", nil), W: &hw}
+	if her, status := ap.serveStatic(root, "/../outside.txt", StaticOptions{}); status != http.StatusNotFound || rw.Body.Len() != 0 {
+		t.Fatal(her, status)
+	}
+
+	w = get("/t_static/list/", nil)
+	if w.Code != http.StatusOK || !contains(w.Body.String(), `href="a.flac"`, `href="sub/"`) || contains(w.Body.String(), ".secret") {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if w = get("/t_static/list/sub", nil); w.Code != http.StatusMovedPermanently {
+		t.Fatal(w.Code)
+	}
+}
+
+func contains(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if !strings.Contains(s, sub) {
+			return false
+		}
+	}
+	return true
+}
+
+// 服务器无法读取的文件按不存在处理
+func TestFileErrorPermission(t *testing.T) {
+	_, status := herFromFileError(&fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission})
+	if status != http.StatusNotFound {
+		t.Fatal(status)
+	}
+}
+
+// 设置了超时的路由中 ServeFile 不缓存文件内容，Range 与条件请求照常
+func TestServeFileTimeout(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "big.flac")
+	_ = os.WriteFile(name, []byte(strings.Repeat("0123456789", 100000)), 0644)
+
+	var buffered int
+	ActionGroup{Path: "/t_static_timeout"}.GET_CONTENT("/song", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		her, status := ap.ServeFile(name)
+		tw := (*ap.W).(*timeoutWriter)
+		tw.mu.Lock()
+		buffered = tw.buf.Len()
+		tw.mu.Unlock()
+		return her, status
+	}).Timeout(time.Second)
+
+	w, _ := serve(httptest.NewRequest(http.MethodGet, "/t_static_timeout/song", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 1000000 || buffered != 0 || w.Header().Get("Content-Type") != "audio/x-flac" {
+		t.Fatal(w.Code, w.Body.Len(), buffered, w.Header())
+	}
+	r := httptest.NewRequest(http.MethodGet, "/t_static_timeout/song", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	if w, _ = serve(r); w.Code != http.StatusPartialContent || w.Body.String() != "2345" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodGet, "/t_static_timeout/song", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	if w, _ = serve(r); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatal(w.Code, w.Body.Len())
+	}
+}
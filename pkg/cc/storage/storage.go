@@ -0,0 +1,217 @@
+// 上传文件的存储后端
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// 文件存储
+	// key 由存储后端生成，可用于之后的 Open 与 Remove
+	// 每次成功的 Save 对应一次 Remove，Remove 只释放该次 Save 取得的引用
+	Storage interface {
+		Save(name string, r io.Reader) (key string, size int64, e error)
+		Open(key string) (io.ReadCloser, error)
+		Remove(key string) error
+	}
+
+	// 本地目录存储，key 为随机文件名并保留原扩展名
+	Local struct {
+		Dir string
+	}
+
+	// 内容寻址存储，key 为内容的 sha256
+	// 相同内容只保存一份，存放于 Dir/<key前两位>/<key>，引用计数存放于同目录的 <key>.refs；
+	// 每次 Save 增加一次引用，Remove 减少一次，计数归零时删除内容
+	// 引用计数只在进程内加锁，同一目录不应由多个进程同时写入
+	CAS struct {
+		Dir string
+		mu  sync.Mutex
+	}
+)
+
+var (
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+func NewLocal(dir string) (*Local, error) {
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return nil, e
+	}
+	return &Local{Dir: dir}, nil
+}
+
+func NewCAS(dir string) (*CAS, error) {
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return nil, e
+	}
+	return &CAS{Dir: dir}, nil
+}
+
+func (l *Local) Save(name string, r io.Reader) (key string, size int64, e error) {
+	ext := strings.ToLower(filepath.Ext(filepath.Base(name)))
+	if len(ext) > 16 || strings.ContainsAny(ext, `/\`) {
+		ext = ""
+	}
+	key = randomHex(16) + ext
+	f, e := os.OpenFile(filepath.Join(l.Dir, key), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if e != nil {
+		return "", 0, e
+	}
+	size, e = io.Copy(f, r)
+	if ce := f.Close(); e == nil {
+		e = ce
+	}
+	if e != nil {
+		_ = os.Remove(f.Name())
+		return "", 0, e
+	}
+	return key, size, nil
+}
+
+func (l *Local) Open(key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	return os.Open(filepath.Join(l.Dir, key))
+}
+
+func (l *Local) Remove(key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	return os.Remove(filepath.Join(l.Dir, key))
+}
+
+func (c *CAS) Save(name string, r io.Reader) (key string, size int64, e error) {
+	tmp, e := os.CreateTemp(c.Dir, ".upload-*")
+	if e != nil {
+		return "", 0, e
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, e = io.Copy(io.MultiWriter(tmp, h), r)
+	if ce := tmp.Close(); e == nil {
+		e = ce
+	}
+	if e != nil {
+		return "", 0, e
+	}
+	key = hex.EncodeToString(h.Sum(nil))
+	p := c.path(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	refs := c.refs(key)
+	if refs == 0 {
+		if e = os.MkdirAll(filepath.Dir(p), 0755); e != nil {
+			return "", 0, e
+		}
+		if e = os.Rename(tmp.Name(), p); e != nil {
+			return "", 0, e
+		}
+	}
+	if e = c.setRefs(key, refs+1); e != nil {
+		if refs == 0 {
+			_ = os.Remove(p)
+		}
+		return "", 0, e
+	}
+	return key, size, nil
+}
+
+func (c *CAS) Open(key string) (io.ReadCloser, error) {
+	if !casKey(key) {
+		return nil, ErrInvalidKey
+	}
+	return os.Open(c.path(key))
+}
+
+// 释放一次引用，没有其他引用时删除内容
+func (c *CAS) Remove(key string) error {
+	if !casKey(key) {
+		return ErrInvalidKey
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	refs := c.refs(key)
+	if refs == 0 {
+		return os.ErrNotExist
+	}
+	if refs > 1 {
+		return c.setRefs(key, refs-1)
+	}
+	if e := os.Remove(c.path(key)); e != nil {
+		return e
+	}
+	return os.Remove(c.path(key) + ".refs")
+}
+
+// 内容的引用数；内容存在而计数文件不存在（旧版本保存）时视为 1
+// 调用时需持有锁
+func (c *CAS) refs(key string) int64 {
+	b, e := os.ReadFile(c.path(key) + ".refs")
+	if e == nil {
+		if n, e := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64); e == nil && n > 0 {
+			return n
+		}
+	}
+	if _, e = os.Stat(c.path(key)); e == nil {
+		return 1
+	}
+	return 0
+}
+
+// 先写入临时文件再替换，避免计数文件写到一半
+func (c *CAS) setRefs(key string, n int64) error {
+	p := c.path(key) + ".refs"
+	tmp, e := os.CreateTemp(filepath.Dir(p), ".refs-*")
+	if e != nil {
+		return e
+	}
+	_, e = tmp.WriteString(strconv.FormatInt(n, 10))
+	if ce := tmp.Close(); e == nil {
+		e = ce
+	}
+	if e == nil {
+		e = os.Rename(tmp.Name(), p)
+	}
+	if e != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return e
+}
+
+func (c *CAS) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key)
+}
+
+// CAS 的 key 为 64 位小写十六进制
+func casKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, e := hex.DecodeString(key)
+	return e == nil && strings.ToLower(key) == key
+}
+
+// key 不允许包含路径
+func validKey(key string) bool {
+	return key != "" && key != "." && key != ".." && !strings.ContainsAny(key, `/\`)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCASRefs(t *testing.T) {
+	c, e := NewCAS(t.TempDir())
+	if e != nil {
+		t.Fatal(e)
+	}
+	k1, n, e := c.Save("a.txt", strings.NewReader("hello"))
+	if e != nil || n != 5 {
+		t.Fatal(e, n)
+	}
+	k2, _, e := c.Save("b.txt", strings.NewReader("hello"))
+	if e != nil || k1 != k2 {
+		t.Fatal(e, k1, k2)
+	}
+
+	// 释放一次引用后内容仍然存在
+	if e = c.Remove(k2); e != nil {
+		t.Fatal(e)
+	}
+	rc, e := c.Open(k1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(b) != "hello" {
+		t.Fatal(string(b))
+	}
+
+	if e = c.Remove(k1); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = c.Open(k1); !os.IsNotExist(e) {
+		t.Fatal(e)
+	}
+	if e = c.Remove(k1); !os.IsNotExist(e) {
+		t.Fatal(e)
+	}
+	for _, k := range []string{"../x", k1 + ".refs", strings.ToUpper(k1), "ab"} {
+		if _, e = c.Open(k); e != ErrInvalidKey {
+			t.Fatal(k, e)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	l, e := NewLocal(t.TempDir())
+	if e != nil {
+		t.Fatal(e)
+	}
+	k, _, e := l.Save("../../a.TXT", strings.NewReader("x"))
+	if e != nil || !strings.HasSuffix(k, ".txt") || strings.Contains(k, "/") {
+		t.Fatal(e, k)
+	}
+	if e = l.Remove(k); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = l.Open("../" + k); e != ErrInvalidKey {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,244 @@
+package cc
+
+/**
+流式响应
+
+大量数据分批写出，不会整体缓存在内存中：
+
+	a.GET_CONTENT("/export", func(ap cc.ActionPackage) (cc.HttpErrReturn, cc.StatusCode) {
+		rows, e := db.Query(...)
+		if e != nil {
+			return cc.HerFromError(e) // 尚未写出任何数据时仍可返回 HER
+		}
+		st := ap.StreamNDJSON()
+		defer st.Close()
+		for rows.Next() {
+			if e := st.Write(scan(rows)); e != nil {
+				return cc.HerFromError(e) // 客户端断开，返回值被忽略
+			}
+		}
+		return cc.HerOk()
+	})
+
+写入会在客户端接收缓慢时阻塞（背压），客户端断开或请求超时后 Write 返回 error
+开始写出之后 handler 返回的 HER 不再写出：返回成功的 HER 时结束流（json 数组写出 ]），
+返回错误时中断连接（http.ErrAbortHandler），客户端因此能发现数据不完整，而不会得到一个看似完整的结果
+*/
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const (
+	streamNDJSON = iota
+	streamJSONArray
+	streamCSV
+)
+
+type (
+	Stream struct {
+		ap          ActionPackage
+		kind        int
+		contentType string
+		bw          *bufio.Writer
+		rc          *http.ResponseController
+		n           int
+		started     bool
+		closed      bool
+		e           error
+		// 每写入 FlushEvery 个元素刷新一次，默认 100
+		FlushEvery int
+	}
+	CSVStream struct {
+		*Stream
+		cw     *csv.Writer
+		header []string
+	}
+)
+
+// 每行一个 json，Content-Type: application/x-ndjson
+func (R ActionPackage) StreamNDJSON() *Stream {
+	return R.newStream(streamNDJSON, "application/x-ndjson")
+}
+
+// 逐个写出 json 数组的元素，Content-Type: application/json
+func (R ActionPackage) StreamJSONArray() *Stream {
+	return R.newStream(streamJSONArray, "application/json")
+}
+
+// header 为空则不写表头，Content-Type: text/csv
+func (R ActionPackage) StreamCSV(header []string) *CSVStream {
+	st := R.newStream(streamCSV, "text/csv; charset=utf-8")
+	return &CSVStream{Stream: st, cw: csv.NewWriter(st.bw), header: header}
+}
+
+func (R ActionPackage) newStream(kind int, ct string) *Stream {
+	return &Stream{
+		ap:          R,
+		kind:        kind,
+		contentType: ct,
+		bw:          bufio.NewWriterSize(*R.W, 32<<10),
+		rc:          http.NewResponseController(*R.W),
+		FlushEvery:  100,
+	}
+}
+
+// 首次写入时才写出响应头
+func (st *Stream) start() {
+	if st.started {
+		return
+	}
+	st.started = true
+	rs := stateFrom(st.ap.Context())
+	rs.written = true
+	rs.mu.Lock()
+	rs.stream = st
+	rs.mu.Unlock()
+	w := *st.ap.W
+	w.Header().Set("Content-Type", st.contentType)
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if st.kind == streamJSONArray {
+		_ = st.bw.WriteByte('[')
+	}
+}
+
+func (st *Stream) check() error {
+	if st.e != nil {
+		return st.e
+	}
+	if st.closed {
+		return ErrStreamClosed
+	}
+	if e := st.ap.Context().Err(); e != nil {
+		st.e = errors.Join(ErrStreamClosed, context.Cause(st.ap.Context()))
+		return st.e
+	}
+	return nil
+}
+
+// 写入一个元素
+func (st *Stream) Write(v interface{}) error {
+	if e := st.check(); e != nil {
+		return e
+	}
+	st.start()
+	jn, e := json.Marshal(v)
+	if e != nil {
+		return e
+	}
+	if st.kind == streamJSONArray && st.n > 0 {
+		_ = st.bw.WriteByte(',')
+	}
+	if _, e = st.bw.Write(jn); e != nil {
+		return st.fail(e)
+	}
+	if st.kind == streamNDJSON {
+		_ = st.bw.WriteByte('\n')
+	}
+	return st.written()
+}
+
+func (st *Stream) written() error {
+	st.n++
+	if st.FlushEvery > 0 && st.n%st.FlushEvery == 0 {
+		return st.Flush()
+	}
+	return nil
+}
+
+func (st *Stream) fail(e error) error {
+	st.e = errors.Join(ErrStreamClosed, e)
+	return st.e
+}
+
+// 将缓冲的数据发送给客户端
+func (st *Stream) Flush() error {
+	if e := st.check(); e != nil {
+		return e
+	}
+	st.start()
+	if e := st.bw.Flush(); e != nil {
+		return st.fail(e)
+	}
+	if e := st.rc.Flush(); e != nil && !errors.Is(e, http.ErrNotSupported) {
+		return st.fail(e)
+	}
+	return nil
+}
+
+// 已写入的元素个数
+func (st *Stream) Count() int {
+	return st.n
+}
+
+// 写出剩余数据，之后不再允许写入
+// 未写入任何元素时输出空的结果（如 []）；json 数组的 ] 在 handler 返回成功的 HER 后才写出，见 finish
+func (st *Stream) Close() error {
+	if st.closed {
+		return st.e
+	}
+	if st.e == nil {
+		st.start()
+		_ = st.Flush()
+	}
+	st.closed = true
+	return st.e
+}
+
+// 由 Route.run 在 handler 返回后调用
+// ok 为 false（handler 返回了错误）时中断连接，不写出结尾
+func (st *Stream) finish(ok bool) {
+	if !ok {
+		LoggerFrom(st.ap.Context()).Warn("[stream] aborted after", st.n, "items")
+		panic(http.ErrAbortHandler)
+	}
+	_ = st.Close()
+	if st.e != nil {
+		return
+	}
+	if st.kind == streamJSONArray {
+		_ = st.bw.WriteByte(']')
+	}
+	if e := st.bw.Flush(); e != nil {
+		st.e = e
+		return
+	}
+	_ = st.rc.Flush()
+}
+
+// 写入一行
+func (cs *CSVStream) WriteRow(row []string) error {
+	if e := cs.check(); e != nil {
+		return e
+	}
+	if !cs.started {
+		cs.start()
+		if len(cs.header) > 0 {
+			_ = cs.cw.Write(cs.header)
+		}
+	}
+	if e := cs.cw.Write(row); e != nil {
+		return cs.fail(e)
+	}
+	// 交给 Stream 的缓冲统一刷新
+	cs.cw.Flush()
+	if e := cs.cw.Error(); e != nil {
+		return cs.fail(e)
+	}
+	return cs.written()
+}
+
+func (cs *CSVStream) Close() error {
+	if !cs.started && cs.e == nil && len(cs.header) > 0 {
+		cs.start()
+		_ = cs.cw.Write(cs.header)
+	}
+	cs.cw.Flush()
+	return cs.Stream.Close()
+}
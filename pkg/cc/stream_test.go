@@ -0,0 +1,80 @@
+package cc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	a := ActionGroup{Path: "/t_stream"}
+	a.GET_CONTENT("/array", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		st := ap.StreamJSONArray()
+		st.FlushEvery = 1
+		defer st.Close()
+		for i := 1; i <= 3; i++ {
+			_ = st.Write(i)
+		}
+		if ap.R.URL.Query().Get("fail") != "" {
+			// 例如 rows.Err()
+			return HerFromError(errors.New("db gone"))
+		}
+		return HerOk()
+	})
+	a.GET_CONTENT("/empty", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		st := ap.StreamJSONArray()
+		defer st.Close()
+		return HerOk()
+	})
+	a.GET_CONTENT("/csv", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		cs := ap.StreamCSV([]string{"id", "name"})
+		defer cs.Close()
+		_ = cs.WriteRow([]string{"1", "a,b"})
+		if ap.R.URL.Query().Get("fail") != "" {
+			return HerFromError(errors.New("db gone"))
+		}
+		return HerOk()
+	})
+	s := httptest.NewServer(http.DefaultServeMux)
+	defer s.Close()
+
+	get := func(path string) (string, string, error) {
+		resp, e := http.Get(s.URL + path)
+		if e != nil {
+			return "", "", e
+		}
+		defer resp.Body.Close()
+		b, e := io.ReadAll(resp.Body)
+		return string(b), resp.Header.Get("Content-Type"), e
+	}
+
+	if b, ct, e := get("/t_stream/array"); e != nil || b != "[1,2,3]" || ct != "application/json" {
+		t.Fatal(b, ct, e)
+	}
+	if b, _, e := get("/t_stream/empty"); e != nil || b != "[]" {
+		t.Fatal(b, e)
+	}
+	if b, ct, e := get("/t_stream/csv"); e != nil || b != "id,name\n1,\"a,b\"\n" || ct != "text/csv; charset=utf-8" {
+		t.Fatal(b, ct, e)
+	}
+	// 中途出错时连接被中断，客户端无法得到完整的结果
+	for _, p := range []string{"/t_stream/array?fail=1", "/t_stream/csv?fail=1"} {
+		if b, _, e := get(p); !errors.Is(e, io.ErrUnexpectedEOF) {
+			t.Fatalf("%s: %q %v", p, b, e)
+		}
+	}
+}
+
+func TestErrorFetcherAbort(t *testing.T) {
+	h := ErrorFetcher()(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatal(v)
+		}
+	}()
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
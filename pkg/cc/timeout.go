@@ -0,0 +1,271 @@
+package cc
+
+/**
+请求超时
+
+	a.GET("/report", buildReport).Timeout(5 * time.Second) // 单个路由
+	a = a.Use(cc.Timeout(10 * time.Second))                // 整个路由组
+
+超时后请求的 context 被取消，并立即返回 503 HER（ERR_TIMEOUT），不等待 handler 返回；
+handler 在超时之后的写入一定被丢弃并返回 http.ErrHandlerTimeout。
+handler 直接写出的内容在返回或 Flush 前先缓存（ServeFile 与 STATIC 不缓存），已 Flush 的响应超时时只取消 context。
+超时次数与 ConcurrencyLimit 拒绝的次数按注册的路由记录在 mwu.RouteStats 中，命令行 route-stats 可查看
+*/
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/cli"
+	"github.com/cyf-gh/ccgo/pkg/cc/middleware"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+type (
+	// 保证超时响应只写出一次，并与 handler 的写入互斥
+	timeoutWriter struct {
+		mu        sync.Mutex
+		ctx       context.Context
+		url       string
+		w         http.ResponseWriter
+		h         http.Header
+		buf       bytes.Buffer
+		status    int
+		committed bool
+		timedOut  bool
+		// 由 unbuffer 设置，WriteHeader 或首次写入时即提交
+		direct bool
+	}
+
+	handlerPanic struct {
+		v     interface{}
+		stack string
+	}
+)
+
+func init() {
+	cli.Register("route-stats", &cli.CliFuncPack{F: func(args []string) error {
+		for _, s := range mwu.RouteStats() {
+			fmt.Printf("%-40s timeouts=%d shed=%d\n", s.Path, s.Timeouts, s.Shed)
+		}
+		return nil
+	}, Desc: "Show per-route statistics", Group: "stats"})
+}
+
+// 路由组的超时中间件，路由自身的 Timeout 更短时以路由为准
+func Timeout(d time.Duration) middleware.MiddewareFunc {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+			runWithTimeout(w, r, routePattern(r), func(tw http.ResponseWriter) {
+				f(tw, r)
+			})
+		}
+	}
+}
+
+// 统计按注册的路由区分，不使用客户端可控的 r.URL.Path，避免记录无限增长
+func routePattern(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	return "(unmatched)"
+}
+
+// 执行 run 直到返回或 r 的 context 超时
+// 超时时写出 503 HER 并返回 true，此时 run 仍可能在执行；
+// 否则 run 直接写出的内容已提交到 w，调用方可以继续写出
+func runWithTimeout(w http.ResponseWriter, r *http.Request, path string, run func(http.ResponseWriter)) bool {
+	tw := &timeoutWriter{ctx: r.Context(), url: r.URL.Path, w: w, h: w.Header().Clone()}
+	// 在 handler 开始前注册，超时后立即写出 503，不依赖下面的 select 先被调度
+	stop := context.AfterFunc(r.Context(), tw.expire)
+	defer stop()
+	done := make(chan *handlerPanic, 1)
+	go func() {
+		var p *handlerPanic
+		defer func() {
+			if v := recover(); v != nil {
+				p = &handlerPanic{v: v, stack: string(debug.Stack())}
+			}
+			done <- p
+		}()
+		run(tw)
+	}()
+
+	var (
+		p        *handlerPanic
+		returned bool
+	)
+	select {
+	case p = <-done:
+		returned = true
+		// 与超时同时发生时以超时为准
+		if !tw.finish() {
+			if p != nil {
+				// 交给 ErrorFetcher 等外层中间件处理
+				panic(p.v)
+			}
+			return false
+		}
+	case <-r.Context().Done():
+		if !errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			// 客户端断开或服务器关闭，等待 handler 自行返回
+			p := <-done
+			tw.finish()
+			if p != nil {
+				panic(p.v)
+			}
+			return false
+		}
+		tw.expire()
+	}
+	mwu.RecordTimeout(path)
+	LoggerFrom(r.Context()).Warn("[timeout]", path)
+	go func() {
+		if !returned {
+			p = <-done
+		}
+		if p != nil {
+			LoggerFrom(r.Context()).Error("[timeout] panic after timeout:", p.v, "\n", p.stack)
+		}
+	}()
+	return true
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() || tw.committed || tw.status != 0 {
+		return
+	}
+	tw.status = status
+	if tw.direct {
+		tw.commit()
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	if tw.direct {
+		tw.commit()
+	}
+	if tw.committed {
+		return tw.w.Write(b)
+	}
+	return tw.buf.Write(b)
+}
+
+// 之后的写入不再缓存，用于 ServeFile 等较大的响应；提交后超时只取消 context
+func (tw *timeoutWriter) unbuffer() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.direct = true
+}
+
+// w 或其包装的 ResponseWriter 为 timeoutWriter 时关闭缓存
+func unbuffer(w http.ResponseWriter) {
+	for {
+		switch x := w.(type) {
+		case *timeoutWriter:
+			x.unbuffer()
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = x.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// 提交已缓存的内容，之后的写入直接写出
+func (tw *timeoutWriter) FlushError() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	tw.commit()
+	return http.NewResponseController(tw.w).Flush()
+}
+
+func (tw *timeoutWriter) Flush() {
+	_ = tw.FlushError()
+}
+
+// 调用时需持有锁
+func (tw *timeoutWriter) commit() {
+	if tw.committed {
+		return
+	}
+	tw.committed = true
+	dst := tw.w.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, vs := range tw.h {
+		dst[k] = vs
+	}
+	if tw.status != 0 {
+		tw.w.WriteHeader(tw.status)
+	}
+	if tw.buf.Len() > 0 {
+		_, _ = tw.w.Write(tw.buf.Bytes())
+		tw.buf.Reset()
+	}
+}
+
+// handler 已返回，提交其设置的响应头与直接写出的内容
+// 已超时时返回 true，此时不提交
+func (tw *timeoutWriter) finish() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return true
+	}
+	tw.commit()
+	return false
+}
+
+func (tw *timeoutWriter) expire() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.expired()
+}
+
+// context 已超时则标记超时，并在未提交时写出 503；调用时需持有锁
+// context 的 Err 在 Done 关闭之前已设置，因此 handler 被唤醒后的写入一定能看到超时
+func (tw *timeoutWriter) expired() bool {
+	if tw.timedOut || !errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		return tw.timedOut
+	}
+	tw.timedOut = true
+	if tw.committed {
+		return true
+	}
+	tw.committed = true
+	her, status := HerTimeout()
+	HttpReturnHER(&tw.w, &her, status, tw.url)
+	return true
+}
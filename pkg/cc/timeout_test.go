@@ -0,0 +1,152 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	mwu "github.com/cyf-gh/ccgo/pkg/cc/middleware/util"
+)
+
+// 超时后只写出一次 503，handler 之后的写入被丢弃
+func TestTimeoutSingleWrite(t *testing.T) {
+	late := make(chan error, 2)
+	a := ActionGroup{Path: "/t_timeout"}
+	a.GET("/ctx", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		<-ap.Context().Done()
+		(*ap.W).Header().Set("X-Late", "1")
+		_, e := (*ap.W).Write([]byte("late body"))
+		late <- e
+		return HerOk()
+	}).Timeout(20 * time.Millisecond)
+	// 不检查 context，超时后才写入
+	a.GET("/ignore", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		time.Sleep(60 * time.Millisecond)
+		(*ap.W).WriteHeader(http.StatusTeapot)
+		_, e := (*ap.W).Write([]byte("late body"))
+		late <- e
+		return HerOk()
+	}).Timeout(20 * time.Millisecond)
+
+	for _, p := range []string{"/t_timeout/ctx", "/t_timeout/ignore"} {
+		st := time.Now()
+		w, her := serve(httptest.NewRequest(http.MethodGet, p, nil))
+		if time.Since(st) > 50*time.Millisecond {
+			t.Errorf("%s: waited for handler (%v)", p, time.Since(st))
+		}
+		if w.Code != http.StatusServiceUnavailable || her.ErrCod != err_code.ERR_TIMEOUT {
+			t.Fatalf("%s: %d %+v", p, w.Code, her)
+		}
+		body := w.Body.String()
+		if e := <-late; !errors.Is(e, http.ErrHandlerTimeout) {
+			t.Errorf("%s: late write returned %v", p, e)
+		}
+		if w.Body.String() != body || strings.Contains(body, "late") || w.Header().Get("X-Late") != "" || w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: response changed after timeout: %d %q %v", p, w.Code, w.Body, w.Header())
+		}
+	}
+}
+
+// 超时前已 Flush 的响应不再写出 503，只取消 context
+func TestTimeoutAfterFlush(t *testing.T) {
+	canceled := make(chan error, 1)
+	ActionGroup{Path: "/t_timeout_flush"}.GET_CONTENT("/x", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		w := *ap.W
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("partial"))
+		_ = http.NewResponseController(w).Flush()
+		<-ap.Context().Done()
+		canceled <- context.Cause(ap.Context())
+		return HerOk()
+	}).Timeout(20 * time.Millisecond)
+
+	w, _ := serve(httptest.NewRequest(http.MethodGet, "/t_timeout_flush/x", nil))
+	if e := <-canceled; !errors.Is(e, context.DeadlineExceeded) {
+		t.Errorf("context: %v", e)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "partial" || !w.Flushed || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("flushed response: %d %q %v", w.Code, w.Body, w.Header())
+	}
+}
+
+// 未超时时 handler 设置的响应头与内容原样写出
+func TestTimeoutPassThrough(t *testing.T) {
+	h := Timeout(time.Second)(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-A", "1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("ok"))
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "ok" || w.Header().Get("X-A") != "1" {
+		t.Errorf("%d %q %v", w.Code, w.Body, w.Header())
+	}
+}
+
+// handler 的 panic 交给外层处理；超时后的 panic 只记录日志
+func TestTimeoutPanic(t *testing.T) {
+	recovered := func(f func()) (v interface{}) {
+		defer func() { v = recover() }()
+		f()
+		return nil
+	}
+	h := Timeout(time.Second)(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	if v := recovered(func() { h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)) }); v != "boom" {
+		t.Errorf("middleware panic: %v", v)
+	}
+
+	ActionGroup{Path: "/t_timeout_panic"}.GET("/x", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		panic("route boom")
+	}).Timeout(time.Second)
+	if v := recovered(func() { serve(httptest.NewRequest(http.MethodGet, "/t_timeout_panic/x", nil)) }); v != "route boom" {
+		t.Errorf("route panic: %v", v)
+	}
+
+	done := make(chan struct{})
+	late := Timeout(10 * time.Millisecond)(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		<-r.Context().Done()
+		panic("after timeout")
+	})
+	w := httptest.NewRecorder()
+	if v := recovered(func() { late(w, httptest.NewRequest(http.MethodGet, "/", nil)) }); v != nil {
+		t.Errorf("panic after timeout propagated: %v", v)
+	}
+	<-done
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d", w.Code)
+	}
+}
+
+// 超时次数按注册的路由记录，而不是请求路径
+func TestTimeoutStatsByPattern(t *testing.T) {
+	a := ActionGroup{Path: "/t_timeout_stats"}.Use(Timeout(10 * time.Millisecond))
+	a.GET_CONTENT("/item/", func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		<-ap.Context().Done()
+		return HerOk()
+	})
+	for _, p := range []string{"/t_timeout_stats/item/a", "/t_timeout_stats/item/b"} {
+		if w, _ := serve(httptest.NewRequest(http.MethodGet, p, nil)); w.Code != http.StatusServiceUnavailable {
+			t.Fatal(p, w.Code)
+		}
+	}
+	var found bool
+	for _, s := range mwu.RouteStats() {
+		if strings.HasPrefix(s.Path, "/t_timeout_stats/item/") {
+			if s.Path != "/t_timeout_stats/item/" || s.Timeouts != 2 {
+				t.Errorf("%+v", s)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Error("timeouts not recorded", mwu.RouteStats())
+	}
+}
@@ -0,0 +1,332 @@
+package cc
+
+/**
+文件上传
+
+	a.UPLOAD("/avatar", cc.UploadOptions{
+		MaxFileSize:  4 << 20,
+		AllowedTypes: []string{"image/*"},
+		Storage:      avatarStorage,
+	}, func(ap cc.ActionPackage) (cc.HttpErrReturn, cc.StatusCode) {
+		f, e := ap.File("avatar")
+		if e != nil {
+			return cc.HerFromError(e)
+		}
+		return cc.HerOkWithString(f.Key)
+	})
+
+文件以流的方式写入存储后端，不会整体读入内存
+解析完成后非文件字段可通过 ap.Bind 的 form tag、ap.UploadValue 或 ap.R.FormValue 取得
+上传失败或 handler 返回错误（含 panic）时已保存的文件会被释放（CAS 中仍被其他上传引用的内容不会被删除），
+handler 出错但仍需保留的文件调用 f.Keep()
+*/
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/storage"
+
+	"github.com/kpango/glg"
+)
+
+type (
+	UploadOptions struct {
+		MaxFileSize  int64    // 单个文件上限，默认 32 MB
+		MaxFiles     int      // 文件个数上限，默认 16
+		MaxFieldSize int64    // 单个非文件字段上限，默认 64 KB
+		MaxBodySize  int64    // 整个 body 上限，默认为 server.cfg 的 [http] max_body_size；允许更大的上传时需显式设置
+		AllowedTypes []string // 允许的 MIME，支持 image/* 形式；为空则不限制
+		Storage      storage.Storage
+	}
+	UploadedFile struct {
+		Field       string
+		Filename    string
+		ContentType string // 由文件内容探测得到，而非客户端声明
+		Size        int64
+		Key         string // 存储后端返回的 key
+		storage     storage.Storage
+		kept        bool
+	}
+	upload struct {
+		files  map[string][]*UploadedFile
+		values url.Values
+		e      error
+	}
+)
+
+var (
+	DefaultUploadOptions = UploadOptions{
+		MaxFileSize:  32 << 20,
+		MaxFiles:     16,
+		MaxFieldSize: 64 << 10,
+	}
+	defaultStorage     storage.Storage
+	defaultStorageOnce sync.Once
+)
+
+func (o UploadOptions) withDefaults() UploadOptions {
+	if o.MaxFileSize <= 0 {
+		o.MaxFileSize = DefaultUploadOptions.MaxFileSize
+	}
+	if o.MaxFiles <= 0 {
+		o.MaxFiles = DefaultUploadOptions.MaxFiles
+	}
+	if o.MaxFieldSize <= 0 {
+		o.MaxFieldSize = DefaultUploadOptions.MaxFieldSize
+	}
+	if o.Storage == nil {
+		o.Storage = DefaultUploadOptions.Storage
+	}
+	if o.Storage == nil {
+		o.Storage = tempStorage()
+	}
+	return o
+}
+
+// 未指定存储时保存至系统临时目录
+func tempStorage() storage.Storage {
+	defaultStorageOnce.Do(func() {
+		s, e := storage.NewLocal(filepath.Join(os.TempDir(), "cc_upload"))
+		if e != nil {
+			glg.Error("create upload temp storage: ", e)
+			return
+		}
+		defaultStorage = s
+	})
+	return defaultStorage
+}
+
+// 添加一个上传请求（POST multipart/form-data）
+// 调用 handler 前完成上传，handler 中通过 ap.Files 取得文件
+func (a ActionGroup) UPLOAD(path string, opts UploadOptions, handler ActionFunc) *Route {
+	opts = opts.withDefaults()
+	rt := a.POST(path, func(ap ActionPackage) (her HttpErrReturn, status StatusCode) {
+		u := ap.parseUpload(opts)
+		if u.e != nil {
+			return HerFromError(u.e)
+		}
+		ok := false
+		defer func() {
+			if !ok {
+				u.removeAll()
+			}
+		}()
+		her, status = handler(ap)
+		ok = status == http.StatusOK && her.ErrCod == err_code.ERR_OK
+		return her, status
+	})
+	return rt.MaxBody(opts.MaxBodySize)
+}
+
+// 取得上传的文件
+// 非 UPLOAD 路由中首次调用时以 DefaultUploadOptions 解析
+func (R ActionPackage) Files(field string) ([]*UploadedFile, error) {
+	u := R.parseUpload(DefaultUploadOptions.withDefaults())
+	if u.e != nil {
+		return nil, u.e
+	}
+	return u.files[field], nil
+}
+
+// 取得上传的第一个文件，不存在时返回参数错误
+func (R ActionPackage) File(field string) (*UploadedFile, error) {
+	fs, e := R.Files(field)
+	if e != nil {
+		return nil, e
+	}
+	if len(fs) == 0 {
+		return nil, FieldErrors{{Field: field, Source: BindForm, Reason: "required"}}
+	}
+	return fs[0], nil
+}
+
+// multipart 中的非文件字段
+func (R ActionPackage) UploadValue(key string) string {
+	u := R.parseUpload(DefaultUploadOptions.withDefaults())
+	return u.values.Get(key)
+}
+
+// 每个请求只解析一次
+func (R ActionPackage) parseUpload(opts UploadOptions) *upload {
+	st := stateFrom(R.Context())
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.upload == nil {
+		st.upload = readUpload(R.R, opts)
+		if st.upload.e != nil {
+			st.upload.removeAll()
+		} else {
+			st.upload.exposeForm(R.R)
+		}
+	}
+	return st.upload
+}
+
+// body 已由 MultipartReader 读取，将非文件字段交给 Bind、FormValue 等使用
+func (u *upload) exposeForm(r *http.Request) {
+	r.MultipartForm = &multipart.Form{Value: u.values, File: map[string][]*multipart.FileHeader{}}
+	r.PostForm = u.values
+	form := url.Values{}
+	for k, vs := range u.values {
+		form[k] = append(form[k], vs...)
+	}
+	for k, vs := range r.URL.Query() {
+		form[k] = append(form[k], vs...)
+	}
+	r.Form = form
+}
+
+func readUpload(r *http.Request, opts UploadOptions) *upload {
+	u := &upload{files: map[string][]*UploadedFile{}, values: url.Values{}}
+	if opts.Storage == nil {
+		u.e = errors.New("upload: no storage available")
+		return u
+	}
+	mr, e := r.MultipartReader()
+	if e != nil {
+		u.e = FieldErrors{{Field: "body", Source: BindBody, Reason: "multipart", Desc: e.Error()}}
+		return u
+	}
+	n := 0
+	for {
+		p, e := mr.NextPart()
+		if e == io.EOF {
+			return u
+		}
+		if e != nil {
+			u.e = uploadReadError(e)
+			return u
+		}
+		field := p.FormName()
+		if p.FileName() == "" {
+			b, e := io.ReadAll(io.LimitReader(p, opts.MaxFieldSize+1))
+			if e != nil {
+				u.e = uploadReadError(e)
+				return u
+			}
+			if int64(len(b)) > opts.MaxFieldSize {
+				u.e = FieldErrors{{Field: field, Source: BindForm, Reason: "max", Desc: "field too large"}}
+				return u
+			}
+			u.values.Add(field, string(b))
+			continue
+		}
+		if n++; n > opts.MaxFiles {
+			u.e = FieldErrors{{Field: field, Source: BindForm, Reason: "max", Desc: "too many files"}}
+			return u
+		}
+		f, e := saveFile(p, opts)
+		if e != nil {
+			u.e = e
+			return u
+		}
+		u.files[field] = append(u.files[field], f)
+	}
+}
+
+func saveFile(p *multipart.Part, opts UploadOptions) (*UploadedFile, error) {
+	field, name := p.FormName(), filepath.Base(p.FileName())
+
+	br := bufio.NewReaderSize(p, 512)
+	head, e := br.Peek(512)
+	if e != nil && e != io.EOF && e != bufio.ErrBufferFull {
+		return nil, uploadReadError(e)
+	}
+	ct := sniffContentType(head, name)
+	if !mimeAllowed(ct, opts.AllowedTypes) {
+		return nil, FieldErrors{{Field: field, Source: BindForm, Reason: "type", Param: ct, Desc: "file type not allowed"}}
+	}
+
+	lr := &io.LimitedReader{R: br, N: opts.MaxFileSize + 1}
+	key, size, e := opts.Storage.Save(name, lr)
+	if e != nil {
+		return nil, uploadReadError(e)
+	}
+	if size > opts.MaxFileSize {
+		_ = opts.Storage.Remove(key)
+		return nil, NewHerError(http.StatusRequestEntityTooLarge, err_code.ERR_INVALID_ARGUMENT, "file \""+name+"\" too large")
+	}
+	glg.Log("[upload] ", field, ": ", name, " (", ct, ") -> ", key)
+	return &UploadedFile{Field: field, Filename: name, ContentType: ct, Size: size, Key: key, storage: opts.Storage}, nil
+}
+
+// body 超出上限的错误原样返回，其余视为参数错误
+func uploadReadError(e error) error {
+	if IsBodyTooLarge(e) {
+		return e
+	}
+	var he *HerError
+	if errors.As(e, &he) {
+		return e
+	}
+	return FieldErrors{{Field: "body", Source: BindBody, Reason: "multipart", Desc: e.Error()}}
+}
+
+// 探测失败时依据扩展名在 ContentType 表中查找
+func sniffContentType(head []byte, name string) string {
+	ct := http.DetectContentType(head)
+	if ct != "application/octet-stream" {
+		mt, _, _ := mime.ParseMediaType(ct)
+		return mt
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	contentTypeMu.RLock()
+	t, ok := ContentType[ext]
+	contentTypeMu.RUnlock()
+	if ok {
+		return strings.TrimSpace(strings.Split(t, ",")[0])
+	}
+	return ct
+}
+
+func mimeAllowed(ct string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == ct || a == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(ct, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// 释放未调用 Keep 的文件
+func (u *upload) removeAll() {
+	for _, fs := range u.files {
+		for _, f := range fs {
+			if !f.kept {
+				_ = f.Remove()
+			}
+		}
+	}
+}
+
+// handler 返回错误时仍保留该文件，例如已将 Key 记录到数据库
+func (f *UploadedFile) Keep() {
+	f.kept = true
+}
+
+// 打开已保存的文件
+func (f *UploadedFile) Open() (io.ReadCloser, error) {
+	return f.storage.Open(f.Key)
+}
+
+// 从存储后端删除文件
+func (f *UploadedFile) Remove() error {
+	return f.storage.Remove(f.Key)
+}
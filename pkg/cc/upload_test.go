@@ -0,0 +1,139 @@
+package cc
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cyf-gh/ccgo/pkg/cc/err_code"
+	"github.com/cyf-gh/ccgo/pkg/cc/storage"
+)
+
+type uploadForm struct {
+	Title string `form:"title" validate:"required"`
+}
+
+func multipartBody(t *testing.T, fields map[string]string, files [][2]string) (*bytes.Buffer, string) {
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	for k, v := range fields {
+		_ = mw.WriteField(k, v)
+	}
+	for _, f := range files {
+		fw, e := mw.CreateFormFile("file", f[0])
+		if e != nil {
+			t.Fatal(e)
+		}
+		_, _ = fw.Write([]byte(f[1]))
+	}
+	_ = mw.Close()
+	return &b, mw.FormDataContentType()
+}
+
+func TestUpload(t *testing.T) {
+	cas, e := storage.NewCAS(t.TempDir())
+	if e != nil {
+		t.Fatal(e)
+	}
+	a := ActionGroup{Path: "/t_upload"}
+	a.UPLOAD("/doc", UploadOptions{MaxFileSize: 16, Storage: cas}, func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		var form uploadForm
+		if e := ap.Bind(&form); e != nil {
+			return HerFromError(e)
+		}
+		f, e := ap.File("file")
+		if e != nil {
+			return HerFromError(e)
+		}
+		return HerOkWithString(form.Title + ":" + f.Key)
+	})
+	post := func(fields map[string]string, files ...[2]string) (*httptest.ResponseRecorder, HttpErrReturn) {
+		b, ct := multipartBody(t, fields, files)
+		r := httptest.NewRequest(http.MethodPost, "/t_upload/doc", b)
+		r.Header.Set("Content-Type", ct)
+		return serve(r)
+	}
+
+	w, her := post(map[string]string{"title": "note"}, [2]string{"a.txt", "hello"})
+	if w.Code != http.StatusOK || !strings.HasPrefix(her.Data, "note:") {
+		t.Fatal(w.Code, her)
+	}
+	key := strings.TrimPrefix(her.Data, "note:")
+
+	// 相同内容的上传在绑定失败后释放引用，不影响之前的上传
+	if _, her = post(nil, [2]string{"b.txt", "hello"}); her.ErrCod != err_code.ERR_INVALID_ARGUMENT {
+		t.Fatal(her)
+	}
+	// 第二个文件超出上限，第一个文件（与之前相同的内容）被释放
+	if w, _ = post(map[string]string{"title": "x"}, [2]string{"c.txt", "hello"}, [2]string{"d.txt", strings.Repeat("x", 32)}); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatal(w.Code)
+	}
+	rc, e := cas.Open(key)
+	if e != nil {
+		t.Fatal("blob of the first upload removed:", e)
+	}
+	rc.Close()
+}
+
+// handler 返回错误时释放未调用 Keep 的文件
+func TestUploadHandlerError(t *testing.T) {
+	cas, e := storage.NewCAS(t.TempDir())
+	if e != nil {
+		t.Fatal(e)
+	}
+	var keys []string
+	a := ActionGroup{Path: "/t_upload_err"}
+	a.UPLOAD("/doc", UploadOptions{Storage: cas}, func(ap ActionPackage) (HttpErrReturn, StatusCode) {
+		fs, _ := ap.Files("file")
+		for _, f := range fs {
+			keys = append(keys, f.Key)
+			if f.Filename == "keep.txt" {
+				f.Keep()
+			}
+		}
+		return HerArgInvalid("file")
+	})
+	b, ct := multipartBody(t, nil, [][2]string{{"drop.txt", "drop"}, {"keep.txt", "keep"}})
+	r := httptest.NewRequest(http.MethodPost, "/t_upload_err/doc", b)
+	r.Header.Set("Content-Type", ct)
+	if _, her := serve(r); her.ErrCod != err_code.ERR_INVALID_ARGUMENT || len(keys) != 2 {
+		t.Fatal(her, keys)
+	}
+	if _, e := cas.Open(keys[0]); e == nil {
+		t.Error("file of the failed request not removed")
+	}
+	rc, e := cas.Open(keys[1])
+	if e != nil {
+		t.Fatal("kept file removed:", e)
+	}
+	rc.Close()
+}
+
+func TestUploadDefaultMaxBody(t *testing.T) {
+	if o := (UploadOptions{}).withDefaults(); o.MaxBodySize != 0 {
+		t.Fatal("should fall back to [http] max_body_size:", o.MaxBodySize)
+	}
+}
+
+func TestSniffContentTypeRace(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			AddContentType("t031", "audio/x-t031")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = sniffContentType([]byte{0, 1, 2}, "a.t031")
+		}()
+	}
+	wg.Wait()
+	if ct := sniffContentType([]byte{0, 1, 2}, "a.T031"); ct != "audio/x-t031" {
+		t.Fatal(ct)
+	}
+}
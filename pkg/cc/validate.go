@@ -0,0 +1,344 @@
+package cc
+
+/**
+请求校验
+
+在 validate tag 中以逗号分隔规则，Bind 完成后自动执行：
+
+	type CreateReq struct {
+		Name  string   `json:"name" validate:"required,min=2,max=32"`
+		Code  string   `json:"code" validate:"len=6,regex=^[0-9]+$"`
+		Kind  string   `json:"kind" validate:"oneof=audio video"`
+		Mail  string   `json:"mail" validate:"email"`
+		Site  string   `json:"site" validate:"url"`
+		Tags  []string `json:"tags" validate:"max=5"`
+		Owner int64    `json:"owner" validate:"required,god"` // god 为自定义规则
+	}
+
+regex 的参数会取走其后的全部内容，因此 regex 必须写在最后
+非 required 的字段为零值（0、""、nil 指针、nil 切片等）时跳过其余规则，
+例如 `validate:"min=1"` 接受 0，需拒绝零值时写作 `validate:"required,min=1"`
+
+未注册的规则、无法编译的 regex、min/max/len 的参数不是数值（或 time.Duration 字段的时长）
+以及用于不支持的字段类型均视为编程错误：Handle 在注册路由时 panic，
+直接调用 Bind 的类型可在启动时以 CheckValidate 检查，否则在首次解析该类型时 panic；
+自定义规则应在注册路由前注册
+*/
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kpango/glg"
+)
+
+type (
+	// v 为已解引用的字段值，param 为 = 之后的参数
+	// 返回 false 表示校验不通过
+	ValidatorFunc func(v reflect.Value, param string) bool
+
+	validateRule struct {
+		name  string
+		param string
+		re    *regexp.Regexp
+	}
+	validateField struct {
+		index  int
+		name   string
+		source string
+		rules  []validateRule
+		nested bool
+	}
+)
+
+var (
+	validators   = map[string]ValidatorFunc{}
+	validatorsMu sync.RWMutex
+	// reflect.Type -> []validateField
+	validateCache sync.Map
+)
+
+func init() {
+	validators["email"] = func(v reflect.Value, _ string) bool {
+		a, e := mail.ParseAddress(v.String())
+		return e == nil && a.Address == v.String()
+	}
+	validators["url"] = func(v reflect.Value, _ string) bool {
+		u, e := url.Parse(v.String())
+		return e == nil && u.Scheme != "" && u.Host != ""
+	}
+	validators["oneof"] = func(v reflect.Value, param string) bool {
+		s := stringOf(v)
+		for _, o := range strings.Fields(param) {
+			if s == o {
+				return true
+			}
+		}
+		return false
+	}
+	validators["min"] = func(v reflect.Value, param string) bool {
+		n, p, ok := measure(v, param)
+		return ok && n >= p
+	}
+	validators["max"] = func(v reflect.Value, param string) bool {
+		n, p, ok := measure(v, param)
+		return ok && n <= p
+	}
+	validators["len"] = func(v reflect.Value, param string) bool {
+		n, p, ok := measure(v, param)
+		return ok && n == p
+	}
+}
+
+// 注册自定义校验规则，同名规则将被覆盖
+func RegisterValidator(name string, f ValidatorFunc) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	if _, ok := validators[name]; ok {
+		glg.Warn("validator:", name, "already exists, recovered.")
+	}
+	validators[name] = f
+}
+
+// 按 validate tag 校验结构体，返回所有不通过的字段
+// v 必须为结构体或结构体指针
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var fes FieldErrors
+	validateStruct(rv, "", &fes)
+	if len(fes) != 0 {
+		return fes
+	}
+	return nil
+}
+
+func validateStruct(sv reflect.Value, prefix string, fes *FieldErrors) {
+	for _, f := range validateFieldsOf(sv.Type()) {
+		fv := sv.Field(f.index)
+		name := prefix + f.name
+		isNil := fv.Kind() == reflect.Pointer && fv.IsNil()
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if f.nested {
+			if !isNil {
+				validateStruct(fv, name+".", fes)
+			}
+			continue
+		}
+
+		if isNil || fv.IsZero() {
+			if len(f.rules) > 0 && f.rules[0].name == "required" {
+				*fes = append(*fes, FieldError{Field: name, Source: f.source, Reason: "required"})
+			}
+			continue
+		}
+		for _, r := range f.rules {
+			if r.name == "required" {
+				continue
+			}
+			if !r.check(fv) {
+				*fes = append(*fes, FieldError{Field: name, Source: f.source, Reason: r.name, Param: r.param})
+				break
+			}
+		}
+	}
+}
+
+func (r validateRule) check(v reflect.Value) bool {
+	if r.re != nil {
+		return r.re.MatchString(stringOf(v))
+	}
+	validatorsMu.RLock()
+	f := validators[r.name]
+	validatorsMu.RUnlock()
+	return f(v, r.param)
+}
+
+// 检查 v 的类型（含嵌套结构体）的 validate tag，用于在启动时发现直接调用 Bind 的类型中的错误
+// v 不为结构体或结构体指针时返回 nil
+func CheckValidate(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	return checkValidateTags(reflect.TypeOf(v))
+}
+
+// 检查结构体（含嵌套结构体）的 validate tag，t 不为结构体时返回 nil
+func checkValidateTags(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	fs, e := parseValidateFields(t)
+	if e != nil {
+		return e
+	}
+	for _, f := range fs {
+		if !f.nested {
+			continue
+		}
+		if e = checkValidateTags(t.Field(f.index).Type); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// 解析并缓存结构体的校验规则，tag 有误时 panic
+func validateFieldsOf(t reflect.Type) []validateField {
+	fs, e := parseValidateFields(t)
+	if e != nil {
+		panic(e)
+	}
+	return fs
+}
+
+func parseValidateFields(t reflect.Type) ([]validateField, error) {
+	if fs, ok := validateCache.Load(t); ok {
+		return fs.([]validateField), nil
+	}
+	var fs []validateField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, source := fieldNameOf(sf)
+		tag := sf.Tag.Get("validate")
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if tag == "" || tag == "-" {
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				fs = append(fs, validateField{index: i, name: name, source: source, nested: true})
+			}
+			continue
+		}
+		rules, e := parseRules(tag, ft)
+		if e != nil {
+			return nil, fmt.Errorf("validate: %s.%s: %w", t, sf.Name, e)
+		}
+		fs = append(fs, validateField{index: i, name: name, source: source, rules: rules})
+	}
+	validateCache.Store(t, fs)
+	return fs, nil
+}
+
+// ft 为已解引用的字段类型，用于检查内置规则的参数
+func parseRules(tag string, ft reflect.Type) ([]validateRule, error) {
+	var rs []validateRule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else if i := strings.IndexByte(tag, ','); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			part, tag = tag, ""
+		}
+		r := validateRule{name: part}
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			r.name, r.param = part[:i], part[i+1:]
+		}
+		switch r.name {
+		case "required":
+		case "regex":
+			re, e := regexp.Compile(r.param)
+			if e != nil {
+				return nil, e
+			}
+			r.re = re
+		case "min", "max", "len":
+			if _, _, ok := measure(reflect.Zero(ft), r.param); !ok {
+				return nil, fmt.Errorf("rule %q cannot apply to %s", part, ft)
+			}
+		case "oneof":
+			if strings.TrimSpace(r.param) == "" {
+				return nil, fmt.Errorf("rule %q needs at least one value", part)
+			}
+		default:
+			validatorsMu.RLock()
+			_, ok := validators[r.name]
+			validatorsMu.RUnlock()
+			if !ok {
+				return nil, fmt.Errorf("unknown rule %q", r.name)
+			}
+		}
+		// required 总是最先检查
+		if r.name == "required" {
+			rs = append([]validateRule{r}, rs...)
+		} else {
+			rs = append(rs, r)
+		}
+	}
+	return rs, nil
+}
+
+// 字段名优先取绑定时使用的名称
+func fieldNameOf(sf reflect.StructField) (name, source string) {
+	for _, src := range bindSources {
+		if n, ok := sf.Tag.Lookup(src); ok && n != "" && n != "-" {
+			return n, src
+		}
+	}
+	if n, ok := sf.Tag.Lookup("json"); ok {
+		if n = strings.Split(n, ",")[0]; n != "" && n != "-" {
+			return n, BindBody
+		}
+	}
+	return sf.Name, BindBody
+}
+
+func stringOf(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+	return ""
+}
+
+// 数值比较大小，字符串比较字符数，切片与 map 比较长度
+// time.Duration 的参数可写作 1s、500ms
+func measure(v reflect.Value, param string) (n, p float64, ok bool) {
+	if v.Type() == durationType {
+		if d, e := time.ParseDuration(param); e == nil {
+			return float64(v.Int()), float64(d), true
+		}
+	}
+	p, e := strconv.ParseFloat(param, 64)
+	if e != nil {
+		return 0, 0, false
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), p, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), p, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), p, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), p, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), p, true
+	}
+	return 0, 0, false
+}
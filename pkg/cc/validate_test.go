@@ -0,0 +1,124 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validateReq struct {
+	Name  string   `json:"name" validate:"required,min=2,max=4"`
+	Code  string   `query:"code" validate:"len=3,regex=^[0-9,]+$"`
+	Kind  string   `json:"kind" validate:"oneof=audio video"`
+	Mail  string   `json:"mail" validate:"email"`
+	Site  string   `json:"site" validate:"url"`
+	Tags  []string `json:"tags" validate:"max=1"`
+	Owner *int64   `json:"owner" validate:"required"`
+	Even  int      `json:"even" validate:"even"`
+	Inner struct {
+		Id int `json:"id" validate:"min=1"`
+	} `json:"inner"`
+}
+
+func TestValidate(t *testing.T) {
+	RegisterValidator("even", func(v reflect.Value, _ string) bool {
+		return v.Int()%2 == 0
+	})
+
+	owner := int64(1)
+	ok := validateReq{Name: "cyf", Code: "1,2", Kind: "audio", Mail: "a@b.cc", Site: "https://b.cc", Owner: &owner, Even: 2}
+	ok.Inner.Id = 1
+	if e := Validate(&ok); e != nil {
+		t.Fatal(e)
+	}
+
+	bad := validateReq{Name: "c", Code: "12a", Kind: "text", Mail: "a", Site: "b.cc", Tags: []string{"a", "b"}, Even: 3}
+	bad.Inner.Id = -1
+	var fes FieldErrors
+	if !errors.As(Validate(bad), &fes) {
+		t.Fatal("expect FieldErrors")
+	}
+	want := map[string]string{
+		"name": "min", "code": "regex", "kind": "oneof", "mail": "email", "site": "url",
+		"tags": "max", "owner": "required", "even": "even", "inner.id": "min",
+	}
+	if len(fes) != len(want) {
+		t.Fatal(fes)
+	}
+	for _, fe := range fes {
+		if want[fe.Field] != fe.Reason {
+			t.Fatal(fe)
+		}
+	}
+}
+
+func TestValidateBadTags(t *testing.T) {
+	type typo struct {
+		Name string `json:"name" validate:"requird"`
+	}
+	type badRegex struct {
+		Inner struct {
+			Code string `json:"code" validate:"regex=^[0-9+$"`
+		} `json:"inner"`
+	}
+	if e := checkValidateTags(reflect.TypeOf(typo{})); e == nil || !strings.Contains(e.Error(), "requird") {
+		t.Fatal(e)
+	}
+	if e := checkValidateTags(reflect.TypeOf(&badRegex{})); e == nil {
+		t.Fatal("expect regex error")
+	}
+	if e := checkValidateTags(reflect.TypeOf(bindReq{})); e != nil {
+		t.Fatal(e)
+	}
+	// 参数或字段类型不适用的内置规则
+	for _, v := range []interface{}{
+		struct {
+			N int `validate:"min=one"`
+		}{},
+		struct {
+			T time.Time `validate:"max=1"`
+		}{},
+		struct {
+			N int `validate:"min=1s"`
+		}{},
+		struct {
+			K string `validate:"oneof="`
+		}{},
+	} {
+		if e := CheckValidate(v); e == nil {
+			t.Errorf("%T: expect error", v)
+		}
+	}
+	if e := CheckValidate(&struct {
+		D *time.Duration `validate:"min=1s,max=1m"`
+	}{}); e != nil {
+		t.Fatal(e)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect panic")
+		}
+	}()
+	Handle(func(string, ActionFunc) *Route { return &Route{} }, "/typo", func(context.Context, typo) (int, error) {
+		return 0, nil
+	})
+}
+
+// 非 required 字段的零值跳过其余规则
+func TestValidateZero(t *testing.T) {
+	type req struct {
+		Id   int `json:"id" validate:"min=1"`
+		Must int `json:"must" validate:"required,min=1"`
+	}
+	var fes FieldErrors
+	if !errors.As(Validate(req{}), &fes) || len(fes) != 1 || fes[0].Field != "must" || fes[0].Reason != "required" {
+		t.Fatal(fes)
+	}
+	if !errors.As(Validate(req{Id: -1, Must: 1}), &fes) || len(fes) != 1 || fes[0].Field != "id" {
+		t.Fatal(fes)
+	}
+}